@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os/signal"
+	"syscall"
 
 	"github.com/ccuetoh/libreapi/pkg/config"
 	libreapi "github.com/ccuetoh/libreapi/pkg/server"
@@ -21,7 +24,30 @@ func main() {
 		log.Fatalf("Unable to start libreapi server: %v", err)
 	}
 
-	if err = server.Start(); err != nil {
-		log.Fatalf("Unable to start libreapi server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Start()
+	}()
+
+	select {
+	case err = <-serveErr:
+		if err != nil {
+			log.Fatalf("Unable to start libreapi server: %v", err)
+		}
+	case <-ctx.Done():
+	}
+
+	// A second signal terminates right away
+	stop()
+	log.Println("Shutting down, draining in-flight requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.Config().HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("Unable to shut down libreapi server cleanly: %v", err)
 	}
 }
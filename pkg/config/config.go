@@ -1,8 +1,18 @@
 package config
 
+import "time"
+
 type Config struct {
-	NewRelic NewRelic `mapstructure:"new_relic"`
-	HTTP     HTTP     `mapstructure:"http"`
+	NewRelic  NewRelic  `mapstructure:"new_relic"`
+	HTTP      HTTP      `mapstructure:"http"`
+	Upstream  Upstream  `mapstructure:"upstream"`
+	Weather   Weather   `mapstructure:"weather"`
+	Alerts    Alerts    `mapstructure:"alerts"`
+	API       API       `mapstructure:"api"`
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+	APIKeys   APIKeys   `mapstructure:"api_keys"`
+	Tracing   Tracing   `mapstructure:"tracing"`
+	Log       Log       `mapstructure:"log"`
 }
 
 type NewRelic struct {
@@ -16,6 +26,121 @@ type HTTP struct {
 	Port                string `mapstructure:"port"`
 	DebugEnabled        bool   `mapstructure:"debug_enabled"`
 	ProxyClientIPHeader string `mapstructure:"proxy_client_ip_header"`
+
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	// Time given to in-flight requests and background workers to finish when shutting down
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	TLS TLS `mapstructure:"tls"`
+}
+
+// TLS enables HTTPS when a certificate and key are set
+type TLS struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// 1.2 or 1.3
+	MinVersion string `mapstructure:"min_version"`
+	// Names of the TLS 1.2 cipher suites allowed, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Go's defaults if empty
+	CipherSuites []string `mapstructure:"cipher_suites"`
+	// How often the certificate files are checked for changes, zero disables reloading
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// Port of the plain HTTP listener redirecting to HTTPS, disabled if empty
+	RedirectPort string `mapstructure:"redirect_port"`
+}
+
+type Upstream struct {
+	MaxStaleness     time.Duration `mapstructure:"max_staleness"`
+	BreakerThreshold int           `mapstructure:"breaker_threshold"`
+	BreakerCooldown  time.Duration `mapstructure:"breaker_cooldown"`
+}
+
+type Weather struct {
+	HistoryPath      string        `mapstructure:"history_path"`
+	HistoryInterval  time.Duration `mapstructure:"history_interval"`
+	HistoryRetention time.Duration `mapstructure:"history_retention"`
+	// Monthly precipitation normals in mm by station code, from January to December
+	PrecipitationNormals map[string][]float64 `mapstructure:"precipitation_normals"`
+}
+
+type Alerts struct {
+	Secret       string        `mapstructure:"secret"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	Rules        []AlertRule   `mapstructure:"rules"`
+}
+
+type AlertRule struct {
+	Name       string  `mapstructure:"name"`
+	Station    int     `mapstructure:"station"`
+	Metric     string  `mapstructure:"metric"`
+	Comparator string  `mapstructure:"comparator"`
+	Threshold  float64 `mapstructure:"threshold"`
+	Webhook    string  `mapstructure:"webhook"`
+}
+
+type API struct {
+	// RFC 3339 dates announced on the routes served without a version prefix
+	UnversionedDeprecation string `mapstructure:"unversioned_deprecation"`
+	UnversionedSunset      string `mapstructure:"unversioned_sunset"`
+}
+
+// RateLimit quotas are formatted as requests per period, such as 1500-H for 1500 requests an hour. The default and
+// routes quotas apply to anonymous clients
+type RateLimit struct {
+	Default string `mapstructure:"default"`
+	// Quotas by route prefix without the version, such as /rut/activities. The longest matching prefix applies
+	Routes map[string]string `mapstructure:"routes"`
+	// Quotas of the API keys by tier name
+	Tiers map[string]Tier `mapstructure:"tiers"`
+	// Shares the counters across replicas when set, such as redis://localhost:6379/0
+	RedisURL string `mapstructure:"redis_url"`
+}
+
+type Tier struct {
+	Default string            `mapstructure:"default"`
+	Routes  map[string]string `mapstructure:"routes"`
+}
+
+type APIKeys struct {
+	// Where clients pass their key, the header taking precedence
+	Header     string   `mapstructure:"header"`
+	QueryParam string   `mapstructure:"query_param"`
+	Keys       []APIKey `mapstructure:"keys"`
+	// JSON file with an array of additional keys, so they can be managed apart from the config
+	File string `mapstructure:"file"`
+}
+
+type APIKey struct {
+	Key  string `mapstructure:"key" json:"key"`
+	Name string `mapstructure:"name" json:"name"`
+	Tier string `mapstructure:"tier" json:"tier"`
+	// Route prefixes without the version the key can access, such as /weather. Every route if empty
+	Groups []string `mapstructure:"groups" json:"groups"`
+}
+
+// Tracing exports OpenTelemetry traces over OTLP/HTTP when an endpoint is set
+type Tracing struct {
+	// Address of the collector, such as localhost:4318
+	Endpoint string `mapstructure:"endpoint"`
+	// Exports over plain HTTP instead of HTTPS
+	Insecure bool `mapstructure:"insecure"`
+	// Sent along every export, such as the credentials of a hosted collector
+	Headers     map[string]string `mapstructure:"headers"`
+	ServiceName string            `mapstructure:"service_name"`
+	// Fraction of the requests traced, from 0 to 1. Requests whose caller already decided are traced if it did
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+type Log struct {
+	// text or json
+	Format string `mapstructure:"format"`
+	// Such as info, or trace to also log the outcome of each handler
+	Level string `mapstructure:"level"`
+	// Carries the ID of each request, taken from the caller when valid and generated otherwise
+	RequestIDHeader string `mapstructure:"request_id_header"`
 }
 
 func Default() *Config {
@@ -25,8 +150,52 @@ func Default() *Config {
 			LogForwardingEnabled: true,
 		},
 		HTTP: HTTP{
-			Port:         "443",
-			DebugEnabled: false,
+			Port:              "443",
+			DebugEnabled:      false,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+			TLS: TLS{
+				MinVersion:     "1.2",
+				ReloadInterval: time.Minute,
+			},
+		},
+		Upstream: Upstream{
+			MaxStaleness:     time.Hour,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+		},
+		Weather: Weather{
+			HistoryInterval:  10 * time.Minute,
+			HistoryRetention: 31 * 24 * time.Hour,
+		},
+		Alerts: Alerts{
+			MaxAttempts:  5,
+			RetryBackoff: time.Second,
+		},
+		API: API{
+			UnversionedDeprecation: "2026-10-19T00:00:00Z",
+			UnversionedSunset:      "2027-04-19T00:00:00Z",
+		},
+		RateLimit: RateLimit{
+			Default: "1500-H",
+			// Each request hits the SII
+			Routes: map[string]string{"/rut/activities": "100-H"},
+		},
+		APIKeys: APIKeys{
+			Header:     "X-API-Key",
+			QueryParam: "api_key",
+		},
+		Tracing: Tracing{
+			ServiceName: "libreapi",
+			SampleRatio: 1,
+		},
+		Log: Log{
+			Format:          "text",
+			Level:           "info",
+			RequestIDHeader: "X-Request-ID",
 		},
 	}
 }
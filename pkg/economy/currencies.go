@@ -10,6 +10,8 @@ import (
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/PuerkitoBio/goquery"
 	"github.com/sahilm/fuzzy"
 )
@@ -81,12 +83,24 @@ type Currency struct {
 	ExchangeRate float64 `json:"exchange_rate"`
 }
 
+var currenciesFingerprint = upstream.Fingerprint{
+	Source: SourceCurrencies,
+	Expectations: []upstream.Expectation{
+		{Selector: "tr > td:nth-child(2)"},
+	},
+}
+
 func parseCurrenciesHTML(r io.ReadCloser) (currencies []*Currency, err error) {
 	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
 		return nil, err
 	}
 
+	err = currenciesFingerprint.Validate(doc)
+	if err != nil {
+		return nil, err
+	}
+
 	doc.Find("tr").EachWithBreak(func(_ int, s *goquery.Selection) bool {
 		name := strings.TrimSpace(s.Children().Get(0).FirstChild.Data)
 
@@ -1,16 +1,18 @@
 package economy
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/ccuetoh/libreapi/pkg/env"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Service interface {
-	GetIndicators() (*Indicators, error)
-	GetCurrencies() ([]*Currency, error)
+	GetIndicators(ctx context.Context) (*Indicators, error)
+	GetCurrencies(ctx context.Context) ([]*Currency, error)
 }
 
 type Handler struct {
@@ -27,21 +29,25 @@ func NewHandler(env *env.Env, service Service) *Handler {
 
 func (h *Handler) Indicators() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		indicators, err := h.service.GetIndicators()
+		indicators, err := h.service.GetIndicators(c)
+		if _, stale := upstream.Staleness(err); err != nil && !stale {
+			h.fetchError(c, err)
+			return
+		}
+
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "unable to get data",
-			})
+			_ = c.Error(err)
+			h.env.Log(c).WithError(err).Warn("serving stale data")
+		}
 
-			h.env.Log(c).Errorf("unable to fecth data: %v", err)
-			return
+		if len(indicators.Warnings) != 0 {
+			h.env.Log(c).Warnf("suspicious indicators: %v", indicators.Warnings)
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{
 			"status": "success",
 			"data":   indicators,
-		})
+		}, err))
 
 		h.env.Log(c).Trace("ok")
 	}
@@ -49,23 +55,23 @@ func (h *Handler) Indicators() gin.HandlerFunc {
 
 func (h *Handler) Currencies() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		currencies, err := h.service.GetCurrencies()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "unable to get data",
-			})
-
-			h.env.Log(c).Errorf("unable to fecth data: %v", err)
+		currencies, err := h.service.GetCurrencies(c)
+		if _, stale := upstream.Staleness(err); err != nil && !stale {
+			h.fetchError(c, err)
 			return
 		}
 
+		if err != nil {
+			_ = c.Error(err)
+			h.env.Log(c).WithError(err).Warn("serving stale data")
+		}
+
 		filter := c.Query("name")
 		if filter == "" {
-			c.JSON(http.StatusOK, gin.H{
+			c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{
 				"status": "success",
 				"data":   currencies,
-			})
+			}, err))
 
 			h.env.Log(c).Trace("ok")
 			return
@@ -73,21 +79,30 @@ func (h *Handler) Currencies() gin.HandlerFunc {
 
 		currencies = filterCurrencies(currencies, filter)
 		if len(currencies) == 0 {
-			c.JSON(http.StatusNotFound, gin.H{
+			c.JSON(http.StatusNotFound, upstream.AnnotateStaleness(gin.H{
 				"status": "success",
 				"data":   nil,
-			})
+			}, err))
 
 			h.env.Log(c).Trace("ok (none matched)")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{
 			"status": "success",
 			"data":   currencies,
-		})
+		}, err))
 
 		h.env.Log(c).Trace("ok")
 		return
 	}
 }
+
+func (h *Handler) fetchError(c *gin.Context, err error) {
+	upstream.RespondFailure(c, h.env.Log(c), err, "unable to get data", func(message string) gin.H {
+		return gin.H{
+			"status":  "error",
+			"message": message,
+		}
+	})
+}
@@ -1,13 +1,16 @@
 package economy
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/ccuetoh/libreapi/internal/test"
 	"github.com/ccuetoh/libreapi/pkg/env"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -21,11 +24,11 @@ type MockService struct {
 	currenciesErr error
 }
 
-func (s MockService) GetIndicators() (*Indicators, error) {
+func (s MockService) GetIndicators(_ context.Context) (*Indicators, error) {
 	return s.indicators, s.indicatorsErr
 }
 
-func (s MockService) GetCurrencies() ([]*Currency, error) {
+func (s MockService) GetCurrencies(_ context.Context) ([]*Currency, error) {
 	return s.currencies, s.currenciesErr
 }
 
@@ -75,6 +78,119 @@ func TestIndicatorsError(t *testing.T) {
 	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
 }
 
+func TestIndicatorsStale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := &Indicators{
+		UF:     1,
+		Dollar: 3,
+	}
+
+	service := MockService{
+		indicators: data,
+		indicatorsErr: &upstream.StaleError{
+			Age: 2 * time.Minute,
+			Err: errors.New("server is on fire"),
+		},
+	}
+
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Indicators()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"stale":true`)
+	assert.Contains(t, recorder.Body.String(), `"age_seconds":120`)
+	test.AssertResponseBody(t, recorder, data)
+}
+
+func TestIndicatorsUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := MockService{
+		indicatorsErr: errors.Wrap(&upstream.OpenError{
+			Source:     "bcentral",
+			RetryAfter: 1500 * time.Millisecond,
+		}, "unable to execute request"),
+	}
+
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Indicators()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+}
+
+func TestIndicatorsStaleUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := &Indicators{UF: 1}
+
+	service := MockService{
+		indicators: data,
+		indicatorsErr: &upstream.StaleError{
+			Age: time.Minute,
+			Err: &upstream.OpenError{Source: "bcentral", RetryAfter: time.Second},
+		},
+	}
+
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Indicators()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	test.AssertResponseBody(t, recorder, data)
+}
+
+func TestIndicatorsLayoutChanged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := MockService{
+		indicatorsErr: &upstream.LayoutError{
+			Source:     SourceIndicators,
+			Mismatches: []string{"expected at least 1 'label#lblValor1_1', got 0"},
+		},
+	}
+
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Indicators()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusBadGateway)
+}
+
+func TestIndicatorsImplausible(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := MockService{
+		indicatorsErr: &ImplausibleError{
+			Issues: []string{"dollar is 34570.36, expected between 300 and 2000"},
+		},
+	}
+
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Indicators()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusBadGateway)
+}
+
 func TestCurrenciesOk(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
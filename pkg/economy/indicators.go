@@ -5,19 +5,45 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
 )
 
 type Indicators struct {
-	UF        float64 `json:"uf"`
-	IVP       float64 `json:"ivp"`
-	Dollar    float64 `json:"dollar"`
-	Euro      float64 `json:"euro"`
-	ITCNM     float64 `json:"itcnm"`
-	OztSilver float64 `json:"ozt_silver"`
-	OztGold   float64 `json:"ozt_gold"`
-	LbCopper  float64 `json:"lb_copper"`
+	UF        float64  `json:"uf"`
+	IVP       float64  `json:"ivp"`
+	Dollar    float64  `json:"dollar"`
+	Euro      float64  `json:"euro"`
+	ITCNM     float64  `json:"itcnm"`
+	OztSilver float64  `json:"ozt_silver"`
+	OztGold   float64  `json:"ozt_gold"`
+	LbCopper  float64  `json:"lb_copper"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// indicatorsFingerprint checks both the values and their series names, so a shift in the labels is caught
+var indicatorsFingerprint = upstream.Fingerprint{
+	Source: SourceIndicators,
+	Expectations: []upstream.Expectation{
+		{Selector: "label#lblSerie1_1", Text: "UF"},
+		{Selector: "label#lblValor1_1"},
+		{Selector: "label#lblSerie1_2", Text: "IVP"},
+		{Selector: "label#lblValor1_2"},
+		{Selector: "label#lblSerie1_3", Text: "Dólar"},
+		{Selector: "label#lblValor1_3"},
+		{Selector: "label#lblSerie1_5", Text: "Euro"},
+		{Selector: "label#lblValor1_5"},
+		{Selector: "label#lblSerie1_7", Text: "Multilateral"},
+		{Selector: "label#lblValor1_7"},
+		{Selector: "label#lblSerie2_3", Text: "Oro"},
+		{Selector: "label#lblValor2_3"},
+		{Selector: "label#lblSerie2_4", Text: "Plata"},
+		{Selector: "label#lblValor2_4"},
+		{Selector: "label#lblSerie2_5", Text: "Cobre"},
+		{Selector: "label#lblValor2_5"},
+	},
 }
 
 func parseIndicatorsHTML(r io.ReadCloser) (*Indicators, error) {
@@ -26,6 +52,11 @@ func parseIndicatorsHTML(r io.ReadCloser) (*Indicators, error) {
 		return nil, errors.Wrap(err, "unable to create document")
 	}
 
+	err = indicatorsFingerprint.Validate(doc)
+	if err != nil {
+		return nil, err
+	}
+
 	indicators := &Indicators{}
 	indicators.UF, err = parseCurrency(doc.Find("label#lblValor1_1").Text())
 	if err != nil {
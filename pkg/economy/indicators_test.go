@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/ccuetoh/libreapi/internal/test"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,3 +39,14 @@ func TestParseIndicatorsHTMLInvalidReader(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, (*Indicators)(nil), got)
 }
+
+func TestParseIndicatorsHTMLLayoutChanged(t *testing.T) {
+	page, err := test.LoadHTML("currencies_ok")
+	if err != nil {
+		t.Fatalf("unable to load test case html: %v", err)
+	}
+
+	got, err := parseIndicatorsHTML(page)
+	assert.True(t, upstream.IsLayoutChanged(err))
+	assert.Equal(t, (*Indicators)(nil), got)
+}
@@ -1,28 +1,47 @@
 package economy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
 )
 
+const (
+	SourceIndicators = "bcentral_indicators"
+	SourceCurrencies = "bcentral_currencies"
+)
+
+var currenciesLinkFingerprint = upstream.Fingerprint{
+	Source: SourceIndicators,
+	Expectations: []upstream.Expectation{
+		{Selector: "#hypLnk1_8[href]"},
+	},
+}
+
 type DefaultService struct {
-	client *http.Client
+	client *upstream.Client
 }
 
-func NewDefaultService() *DefaultService {
+func NewDefaultService(opts ...upstream.Option) *DefaultService {
+	client := upstream.NewClient(5*time.Second, opts...)
+	client.Register(SourceIndicators, SourceCurrencies)
+
 	return &DefaultService{
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		client: client,
 	}
 }
 
-func (s *DefaultService) GetIndicators() (*Indicators, error) {
-	res, err := s.client.Get("https://si3.bcentral.cl/Indicadoressiete/secure/Indicadoresdiarios.aspx")
+func (s *DefaultService) GetIndicators(ctx context.Context) (indicators *Indicators, err error) {
+	fetch := s.client.Track(SourceIndicators)
+	defer fetch.Done(&err)
+
+	res, err := s.client.GetContext(ctx, "https://si3.bcentral.cl/Indicadoressiete/secure/Indicadoresdiarios.aspx")
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +52,9 @@ func (s *DefaultService) GetIndicators() (*Indicators, error) {
 		return nil, fmt.Errorf("non ok status: %d %s", res.StatusCode, res.Status)
 	}
 
-	indicators, err := parseIndicatorsHTML(res.Body)
+	fetch.Fetched()
+
+	indicators, err = parseIndicatorsHTML(res.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -41,13 +62,16 @@ func (s *DefaultService) GetIndicators() (*Indicators, error) {
 	return indicators, nil
 }
 
-func (s *DefaultService) GetCurrencies() ([]*Currency, error) {
-	url, err := s.getDailyCurrenciesURL()
+func (s *DefaultService) GetCurrencies(ctx context.Context) (currencies []*Currency, err error) {
+	url, err := s.getDailyCurrenciesURL(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get url")
 	}
 
-	res, err := s.client.Get(url)
+	fetch := s.client.Track(SourceCurrencies)
+	defer fetch.Done(&err)
+
+	res, err := s.client.GetContext(ctx, url)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to execute request")
 	}
@@ -58,7 +82,9 @@ func (s *DefaultService) GetCurrencies() ([]*Currency, error) {
 		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
 	}
 
-	currencies, err := parseCurrenciesHTML(res.Body)
+	fetch.Fetched()
+
+	currencies, err = parseCurrenciesHTML(res.Body)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to parse html")
 	}
@@ -66,21 +92,30 @@ func (s *DefaultService) GetCurrencies() ([]*Currency, error) {
 	return currencies, nil
 }
 
-func (s *DefaultService) getDailyCurrenciesURL() (string, error) {
-	resp, err := s.client.Get("https://si3.bcentral.cl/Indicadoressiete/secure/IndicadoresDiarios.aspx")
+func (s *DefaultService) getDailyCurrenciesURL(ctx context.Context) (url string, err error) {
+	fetch := s.client.Track(SourceIndicators)
+	defer fetch.Done(&err)
+
+	resp, err := s.client.GetContext(ctx, "https://si3.bcentral.cl/Indicadoressiete/secure/IndicadoresDiarios.aspx")
 	if err != nil {
 		return "", errors.Wrap(err, "unable to execute request")
 	}
 
+	defer resp.Body.Close()
+
+	fetch.Fetched()
+
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
 		return "", err
 	}
 
-	url, exists := doc.Find("#hypLnk1_8").Attr("href")
-	if !exists {
-		return "", errors.New("no url found")
+	err = currenciesLinkFingerprint.Validate(doc)
+	if err != nil {
+		return "", err
 	}
 
+	url, _ = doc.Find("#hypLnk1_8").Attr("href")
+
 	return "https://si3.bcentral.cl/Indicadoressiete/secure/" + url, nil
 }
@@ -1,6 +1,7 @@
 package economy
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -11,7 +12,7 @@ func TestGetIndicators(t *testing.T) {
 	service := NewDefaultService()
 	service.client.Timeout = time.Minute
 
-	indicators, err := service.GetIndicators()
+	indicators, err := service.GetIndicators(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, indicators)
 }
@@ -20,7 +21,7 @@ func TestGetCurrencies(t *testing.T) {
 	service := NewDefaultService()
 	service.client.Timeout = time.Minute
 
-	indicators, err := service.GetCurrencies()
+	indicators, err := service.GetCurrencies(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, indicators)
 }
@@ -0,0 +1,36 @@
+package economy
+
+import (
+	"context"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+)
+
+// StaleService wraps a Service and falls back to the last successful result when the upstream fails. Stale results
+// are returned together with an upstream.StaleError.
+type StaleService struct {
+	service    Service
+	indicators *upstream.LastKnownGood[*Indicators]
+	currencies *upstream.LastKnownGood[[]*Currency]
+}
+
+func NewStaleService(service Service, maxStaleness time.Duration) *StaleService {
+	return &StaleService{
+		service:    service,
+		indicators: upstream.NewLastKnownGood[*Indicators](maxStaleness),
+		currencies: upstream.NewLastKnownGood[[]*Currency](maxStaleness),
+	}
+}
+
+func (s *StaleService) GetIndicators(ctx context.Context) (*Indicators, error) {
+	return s.indicators.Fetch(func() (*Indicators, error) {
+		return s.service.GetIndicators(ctx)
+	})
+}
+
+func (s *StaleService) GetCurrencies(ctx context.Context) ([]*Currency, error) {
+	return s.currencies.Fetch(func() ([]*Currency, error) {
+		return s.service.GetCurrencies(ctx)
+	})
+}
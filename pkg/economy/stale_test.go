@@ -0,0 +1,45 @@
+package economy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleServiceIndicators(t *testing.T) {
+	data := &Indicators{UF: 1, Dollar: 2}
+
+	mock := &MockService{indicators: data}
+	service := NewStaleService(mock, time.Hour)
+
+	got, err := service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	mock.indicators = nil
+	mock.indicatorsErr = errors.New("server is on fire")
+
+	got, err = service.GetIndicators(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, data, got)
+
+	_, stale := upstream.Staleness(err)
+	assert.True(t, stale)
+}
+
+func TestStaleServiceCurrenciesNoPrevious(t *testing.T) {
+	mock := &MockService{currenciesErr: errors.New("server is on fire")}
+	service := NewStaleService(mock, time.Hour)
+
+	got, err := service.GetCurrencies(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, ([]*Currency)(nil), got)
+
+	_, stale := upstream.Staleness(err)
+	assert.False(t, stale)
+}
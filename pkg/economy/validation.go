@@ -0,0 +1,147 @@
+package economy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+)
+
+// ImplausibleError lists the indicators outside any realistic value. It matches upstream.ErrImplausible.
+type ImplausibleError struct {
+	Issues []string
+}
+
+func (e *ImplausibleError) Error() string {
+	return fmt.Sprintf("%v: %s", upstream.ErrImplausible, strings.Join(e.Issues, "; "))
+}
+
+func (e *ImplausibleError) Is(target error) bool {
+	return target == upstream.ErrImplausible
+}
+
+type plausibility struct {
+	name  string
+	value func(i *Indicators) float64
+	min   float64
+	max   float64
+	// maxDailyChange is the largest relative change expected between two consecutive days
+	maxDailyChange float64
+}
+
+var indicatorChecks = []plausibility{
+	{name: "uf", value: func(i *Indicators) float64 { return i.UF }, min: 20000, max: 80000, maxDailyChange: 0.005},
+	{name: "ivp", value: func(i *Indicators) float64 { return i.IVP }, min: 20000, max: 80000, maxDailyChange: 0.005},
+	{name: "dollar", value: func(i *Indicators) float64 { return i.Dollar }, min: 300, max: 2000, maxDailyChange: 0.08},
+	{name: "euro", value: func(i *Indicators) float64 { return i.Euro }, min: 300, max: 2500, maxDailyChange: 0.08},
+	{name: "itcnm", value: func(i *Indicators) float64 { return i.ITCNM }, min: 50, max: 300, maxDailyChange: 0.08},
+	{name: "ozt_gold", value: func(i *Indicators) float64 { return i.OztGold }, min: 500, max: 10000, maxDailyChange: 0.1},
+	{name: "ozt_silver", value: func(i *Indicators) float64 { return i.OztSilver }, min: 5, max: 200, maxDailyChange: 0.15},
+	{name: "lb_copper", value: func(i *Indicators) float64 { return i.LbCopper }, min: 0.5, max: 20, maxDailyChange: 0.15},
+}
+
+// ValidatingService wraps a Service and checks the plausibility of every indicators snapshot. Snapshots with values
+// out of range are rejected with an ImplausibleError, while unusual changes against the last snapshot without warnings
+// are served with warnings.
+type ValidatingService struct {
+	service Service
+	now     func() time.Time
+
+	mu sync.Mutex
+	// Baseline of the changes, only moved by snapshots without warnings so a flagged one is flagged until it's fixed
+	last   *Indicators
+	lastAt time.Time
+}
+
+func NewValidatingService(service Service) *ValidatingService {
+	return &ValidatingService{
+		service: service,
+		now:     time.Now,
+	}
+}
+
+func (s *ValidatingService) GetIndicators(ctx context.Context) (*Indicators, error) {
+	indicators, err := s.service.GetIndicators(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRanges(indicators)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	checked := *indicators
+	checked.Warnings = checkChanges(s.last, s.lastAt, &checked, now)
+
+	if len(checked.Warnings) == 0 {
+		s.last = &checked
+		s.lastAt = now
+	}
+
+	return &checked, nil
+}
+
+func (s *ValidatingService) GetCurrencies(ctx context.Context) ([]*Currency, error) {
+	return s.service.GetCurrencies(ctx)
+}
+
+func checkRanges(indicators *Indicators) error {
+	var issues []string
+	for _, check := range indicatorChecks {
+		value := check.value(indicators)
+		if value < check.min || value > check.max {
+			issues = append(issues, fmt.Sprintf("%s is %g, expected between %g and %g", check.name, value, check.min, check.max))
+		}
+	}
+
+	if len(issues) != 0 {
+		return &ImplausibleError{Issues: issues}
+	}
+
+	return nil
+}
+
+func checkChanges(last *Indicators, lastAt time.Time, indicators *Indicators, now time.Time) []string {
+	if last == nil {
+		return nil
+	}
+
+	// Allow for the changes of every day elapsed since the last snapshot
+	days := math.Max(1, math.Ceil(now.Sub(lastAt).Hours()/24))
+
+	var warnings []string
+	for _, check := range indicatorChecks {
+		previous := check.value(last)
+		change := math.Abs(check.value(indicators)-previous) / previous
+		if change > check.maxDailyChange*days {
+			warnings = append(warnings, fmt.Sprintf("%s changed %.1f%% since the last snapshot", check.name, change*100))
+		}
+	}
+
+	if ufPeriod(now).Equal(ufPeriod(lastAt)) && indicators.UF < last.UF {
+		warnings = append(warnings, "uf decreased within the month")
+	}
+
+	return warnings
+}
+
+// ufPeriod returns the start of the UF period t belongs to. The UF is scheduled from the 10th of a month to the 9th of
+// the next one.
+func ufPeriod(t time.Time) time.Time {
+	year, month, day := t.Date()
+	if day < 10 {
+		month--
+	}
+
+	return time.Date(year, month, 10, 0, 0, 0, 0, t.Location())
+}
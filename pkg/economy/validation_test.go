@@ -0,0 +1,185 @@
+package economy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func validIndicators() *Indicators {
+	return &Indicators{
+		UF:        34570.36,
+		IVP:       35175.80,
+		Dollar:    945.31,
+		Euro:      943.61,
+		ITCNM:     123.51,
+		OztSilver: 19.54,
+		OztGold:   1661.54,
+		LbCopper:  3.58,
+	}
+}
+
+func TestValidatingServiceOk(t *testing.T) {
+	mock := &MockService{indicators: validIndicators()}
+	service := NewValidatingService(mock)
+
+	got, err := service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, validIndicators(), got)
+}
+
+func TestValidatingServiceOutOfRange(t *testing.T) {
+	shifted := validIndicators()
+	shifted.Dollar = shifted.UF
+
+	mock := &MockService{indicators: shifted}
+	service := NewValidatingService(mock)
+
+	got, err := service.GetIndicators(context.Background())
+	assert.True(t, errors.Is(err, upstream.ErrImplausible))
+	assert.Equal(t, (*Indicators)(nil), got)
+
+	var implausibleErr *ImplausibleError
+	assert.True(t, errors.As(err, &implausibleErr))
+	assert.Len(t, implausibleErr.Issues, 1)
+}
+
+func TestValidatingServiceChanges(t *testing.T) {
+	now := time.Date(2022, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	mock := &MockService{indicators: validIndicators()}
+	service := NewValidatingService(mock)
+	service.now = func() time.Time { return now }
+
+	_, err := service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+
+	now = now.AddDate(0, 0, 1)
+
+	jump := validIndicators()
+	jump.Dollar *= 1.2
+	jump.UF -= 10
+	mock.indicators = jump
+
+	got, err := service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"dollar changed 20.0% since the last snapshot",
+		"uf decreased within the month",
+	}, got.Warnings)
+
+	// The dollar moved slowly over three weeks, and the UF decrease happened on a new period
+	now = now.AddDate(0, 0, 20)
+
+	slow := validIndicators()
+	slow.Dollar = jump.Dollar * 1.3
+	slow.UF = jump.UF - 10
+	mock.indicators = slow
+
+	got, err = service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, got.Warnings)
+}
+
+func TestValidatingServiceUFPeriod(t *testing.T) {
+	cases := []struct {
+		last     time.Time
+		now      time.Time
+		expected []string
+	}{
+		// The period starts on the 10th
+		{
+			last:     time.Date(2022, 10, 9, 12, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 10, 10, 12, 0, 0, 0, time.UTC),
+			expected: nil,
+		},
+		{
+			last:     time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 10, 10, 12, 0, 0, 0, time.UTC),
+			expected: []string{"uf decreased within the month"},
+		},
+		// And spans the start of the next month
+		{
+			last:     time.Date(2022, 10, 31, 12, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC),
+			expected: []string{"uf decreased within the month"},
+		},
+		{
+			last:     time.Date(2022, 12, 31, 12, 0, 0, 0, time.UTC),
+			now:      time.Date(2023, 1, 9, 12, 0, 0, 0, time.UTC),
+			expected: []string{"uf decreased within the month"},
+		},
+	}
+
+	for _, tc := range cases {
+		now := tc.last
+
+		mock := &MockService{indicators: validIndicators()}
+		service := NewValidatingService(mock)
+		service.now = func() time.Time { return now }
+
+		_, err := service.GetIndicators(context.Background())
+		assert.NoError(t, err)
+
+		decreased := validIndicators()
+		decreased.UF -= 10
+		mock.indicators = decreased
+		now = tc.now
+
+		got, err := service.GetIndicators(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, got.Warnings, tc.now)
+	}
+}
+
+func TestValidatingServiceKeepsBaseline(t *testing.T) {
+	now := time.Date(2022, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	base := validIndicators()
+	base.Euro = 1100
+
+	mock := &MockService{indicators: base}
+	service := NewValidatingService(mock)
+	service.now = func() time.Time { return now }
+
+	_, err := service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+
+	// The euro lands in the dollar column and the other way around
+	swapped := validIndicators()
+	swapped.Dollar, swapped.Euro = base.Euro, base.Dollar
+	mock.indicators = swapped
+
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Hour)
+
+		got, err := service.GetIndicators(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"dollar changed 16.4% since the last snapshot",
+			"euro changed 14.1% since the last snapshot",
+		}, got.Warnings)
+	}
+
+	// Fixed upstream
+	now = now.Add(time.Hour)
+	mock.indicators = base
+
+	got, err := service.GetIndicators(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, got.Warnings)
+}
+
+func TestValidatingServiceError(t *testing.T) {
+	mock := &MockService{indicatorsErr: errors.New("server is on fire")}
+	service := NewValidatingService(mock)
+
+	got, err := service.GetIndicators(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, (*Indicators)(nil), got)
+}
@@ -9,23 +9,49 @@ import (
 	"github.com/newrelic/go-agent/v3/integrations/nrgin"
 	"github.com/newrelic/go-agent/v3/newrelic"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const tracerName = "github.com/ccuetoh/libreapi"
+
+// RequestIDKey holds the ID of the request in the gin.Context
+const RequestIDKey = "requestID"
+
 type Env struct {
 	Logger   *logrus.Logger
 	Cfg      *config.Config
 	NewRelic *newrelic.Application
+	// Only set when tracing is enabled
+	TracerProvider trace.TracerProvider
 }
 
+// Log returns an entry tagged with the request and trace IDs of c, so every line of a request can be correlated.
 func (e *Env) Log(c *gin.Context) *logrus.Entry {
-	if e.NewRelic == nil {
-		return e.Logger.WithContext(context.Background())
+	ctx := context.Background()
+	if e.NewRelic != nil {
+		ctx = newrelic.NewContext(ctx, nrgin.Transaction(c))
+	}
+
+	entry := e.Logger.WithContext(ctx)
+
+	if requestID := c.GetString(RequestIDKey); requestID != "" {
+		entry = entry.WithField("request_id", requestID)
 	}
 
-	txn := nrgin.Transaction(c)
-	ctx := newrelic.NewContext(context.Background(), txn)
+	if span := trace.SpanContextFromContext(c); span.IsValid() {
+		entry = entry.WithField("trace_id", span.TraceID().String())
+	}
+
+	return entry
+}
+
+// Tracer creates the spans of the application, which are discarded when tracing is disabled.
+func (e *Env) Tracer() trace.Tracer {
+	if e.TracerProvider == nil {
+		return trace.NewNoopTracerProvider().Tracer(tracerName)
+	}
 
-	return e.Logger.WithContext(ctx)
+	return e.TracerProvider.Tracer(tracerName)
 }
 
 func NewTestEnv() *Env {
@@ -1,18 +1,20 @@
 package rut
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/ccuetoh/libreapi/pkg/env"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Service interface {
-	GetProfile(rut RUT) (*SIIProfile, error)
+	GetProfile(ctx context.Context, rut RUT) (*SIIProfile, error)
 }
 
 type Handler struct {
@@ -139,14 +141,9 @@ func (h *Handler) Activity() gin.HandlerFunc {
 			return
 		}
 
-		profile, err := h.service.GetProfile(rut)
+		profile, err := h.service.GetProfile(c, rut)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "unable to fetch the data",
-			})
-
-			h.env.Log(c).Errorf("unable to fetch data: %v", err)
+			h.fetchError(c, err)
 			return
 		}
 
@@ -232,3 +229,12 @@ func (h *Handler) Generate() gin.HandlerFunc {
 		h.env.Log(c).Trace("ok")
 	}
 }
+
+func (h *Handler) fetchError(c *gin.Context, err error) {
+	upstream.RespondFailure(c, h.env.Log(c), err, "unable to fetch the data", func(message string) gin.H {
+		return gin.H{
+			"status":  "error",
+			"message": message,
+		}
+	})
+}
@@ -2,6 +2,7 @@ package rut
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,9 +11,11 @@ import (
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/ccuetoh/libreapi/internal/test"
 	"github.com/ccuetoh/libreapi/pkg/env"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/gin-gonic/gin"
 	jsoniter "github.com/json-iterator/go"
@@ -24,7 +27,7 @@ type MockService struct {
 	profileErr error
 }
 
-func (s MockService) GetProfile(_ RUT) (*SIIProfile, error) {
+func (s MockService) GetProfile(_ context.Context, _ RUT) (*SIIProfile, error) {
 	return s.profile, s.profileErr
 }
 
@@ -200,6 +203,27 @@ func TestActivityError(t *testing.T) {
 	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
 }
 
+func TestActivityUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := MockService{profileErr: &upstream.OpenError{
+		Source:     "sii",
+		RetryAfter: 10 * time.Second,
+	}}
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?rut=4100738-9")
+
+	handler.Activity()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, "10", recorder.Header().Get("Retry-After"))
+}
+
 func TestActivityNoRut(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
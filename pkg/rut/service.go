@@ -1,6 +1,7 @@
 package rut
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -13,20 +14,28 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/PuerkitoBio/goquery"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 )
 
+const (
+	SourceCaptcha = "sii_captcha"
+	SourceSTC     = "sii_stc"
+)
+
 type DefaultService struct {
-	client *http.Client
+	client *upstream.Client
 }
 
-func NewDefaultService() *DefaultService {
+func NewDefaultService(opts ...upstream.Option) *DefaultService {
+	client := upstream.NewClient(3*time.Second, opts...)
+	client.Register(SourceCaptcha, SourceSTC)
+
 	return &DefaultService{
-		client: &http.Client{
-			Timeout: 3 * time.Second,
-		},
+		client: client,
 	}
 }
 
@@ -43,12 +52,15 @@ type Activity struct {
 	Date         time.Time `json:"date"`
 }
 
-func (s *DefaultService) GetProfile(rut RUT) (*SIIProfile, error) {
-	code, captcha, err := s.getCaptcha()
+func (s *DefaultService) GetProfile(ctx context.Context, rut RUT) (profile *SIIProfile, err error) {
+	code, captcha, err := s.getCaptcha(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get captcha")
 	}
 
+	fetch := s.client.Track(SourceSTC)
+	defer fetch.Done(&err)
+
 	form := url.Values{}
 	form.Add("RUT", rut.String()[:len(rut.Digits)])
 	form.Add("DV", rut.VD.String())
@@ -57,10 +69,15 @@ func (s *DefaultService) GetProfile(rut RUT) (*SIIProfile, error) {
 	form.Add("txt_captcha", code) // code is expected in "txt_captcha" and captcha in "txt_code"
 	form.Add("txt_code", captcha)
 
-	res, err := s.client.Post(
-		"https://zeus.sii.cl/cvc_cgi/stc/getstc",
-		"application/x-www-form-urlencoded",
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://zeus.sii.cl/cvc_cgi/stc/getstc",
 		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.client.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to execute request")
 	}
@@ -71,20 +88,29 @@ func (s *DefaultService) GetProfile(rut RUT) (*SIIProfile, error) {
 		return nil, fmt.Errorf("non ok status: %d %s", res.StatusCode, res.Status)
 	}
 
+	fetch.Fetched()
+
 	return parseActivitiesHTML(res.Body)
 }
 
-func (s *DefaultService) getCaptcha() (code string, captcha string, err error) {
-	resp, err := s.client.Get("https://zeus.sii.cl/cvc_cgi/stc/CViewCaptcha.cgi?oper=0")
+func (s *DefaultService) getCaptcha(ctx context.Context) (code string, captcha string, err error) {
+	fetch := s.client.Track(SourceCaptcha)
+	defer fetch.Done(&err)
+
+	resp, err := s.client.GetContext(ctx, "https://zeus.sii.cl/cvc_cgi/stc/CViewCaptcha.cgi?oper=0")
 	if err != nil {
 		return "", "", errors.Wrap(err, "unable to execute request")
 	}
 
+	defer resp.Body.Close()
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", "", errors.Wrap(err, "unable to read response body")
 	}
 
+	fetch.Fetched()
+
 	data := &struct {
 		Code string `json:"txtCaptcha"`
 	}{}
@@ -106,6 +132,21 @@ func (s *DefaultService) getCaptcha() (code string, captcha string, err error) {
 	return data.Code, string(codeDecoded)[36:40], nil
 }
 
+var profileFingerprint = upstream.Fingerprint{
+	Source: SourceSTC,
+	Expectations: []upstream.Expectation{
+		{Selector: "#contenedor > div:nth-child(4)"},
+	},
+}
+
+// activitiesFingerprint is only checked when the page has tables, as profiles without records don't include them
+var activitiesFingerprint = upstream.Fingerprint{
+	Source: SourceSTC,
+	Expectations: []upstream.Expectation{
+		{Selector: "table.tabla:nth-child(27) > tbody:nth-child(1) > tr:nth-child(1)", Text: "Actividades"},
+	},
+}
+
 func parseActivitiesHTML(r io.ReadCloser) (*SIIProfile, error) {
 	layout := "02-01-2006"
 
@@ -114,12 +155,24 @@ func parseActivitiesHTML(r io.ReadCloser) (*SIIProfile, error) {
 		return nil, errors.Wrap(err, "unable to create document")
 	}
 
+	err = profileFingerprint.Validate(doc)
+	if err != nil {
+		return nil, err
+	}
+
 	name := clean(doc.Find("#contenedor > div:nth-child(4)"))
 	if name == "**" {
 		// No record found
 		return &SIIProfile{}, nil
 	}
 
+	if doc.Find("table.tabla").Length() != 0 {
+		err = activitiesFingerprint.Validate(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var activities []*Activity
 	doc.Find("table.tabla:nth-child(27) > tbody:nth-child(1) > tr").EachWithBreak(func(i int, s *goquery.Selection) bool {
 		if i == 0 {
@@ -1,9 +1,12 @@
 package rut
 
 import (
+	"context"
 	"testing"
 
 	"github.com/ccuetoh/libreapi/internal/test"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,7 +16,7 @@ func TestGetProfile(t *testing.T) {
 	rut, err := parseRUT("3.632.455-4", false)
 	assert.NoError(t, err)
 
-	profile, err := service.GetProfile(rut)
+	profile, err := service.GetProfile(context.Background(), rut)
 	assert.NoError(t, err)
 	assert.NotNil(t, profile)
 }
@@ -57,6 +60,17 @@ func TestParseProfileHTMLBadCode(t *testing.T) {
 	assert.Equal(t, (*SIIProfile)(nil), got)
 }
 
+func TestParseProfileHTMLLayoutChanged(t *testing.T) {
+	page, err := test.LoadHTML("indicators_ok")
+	if err != nil {
+		t.Fatalf("unable to load test case html: %v", err)
+	}
+
+	got, err := parseActivitiesHTML(page)
+	assert.True(t, upstream.IsLayoutChanged(err))
+	assert.Equal(t, (*SIIProfile)(nil), got)
+}
+
 func TestParseCurrenciesHTMLInvalidReader(t *testing.T) {
 	page, err := test.LoadHTML("activities_bad_date")
 	if err != nil {
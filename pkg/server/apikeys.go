@@ -0,0 +1,263 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	apiKeyContextKey = "apiKey"
+	minAPIKeyLength  = 16
+	// Route reporting the usage of the key, which every key can access regardless of its groups
+	usagePath = "/keys/usage"
+)
+
+type apiKey struct {
+	name   string
+	tier   string
+	groups []string
+	usage  keyUsage
+}
+
+// keyUsage counts the requests made with a key since the server started.
+type keyUsage struct {
+	requests atomic.Int64
+	// Rejected because the route isn't allowed for the key
+	rejected atomic.Int64
+	// Rejected because the quota of the tier was reached
+	limited  atomic.Int64
+	lastUsed atomic.Int64
+}
+
+type keyUsageReport struct {
+	Name     string     `json:"name"`
+	Tier     string     `json:"tier"`
+	Groups   []string   `json:"groups"`
+	Requests int64      `json:"requests"`
+	Rejected int64      `json:"rejected"`
+	Limited  int64      `json:"limited"`
+	LastUsed *time.Time `json:"last_used"`
+}
+
+func (k *apiKey) allows(path string) bool {
+	if len(k.groups) == 0 || path == usagePath {
+		return true
+	}
+
+	for _, group := range k.groups {
+		if matchesPrefix(path, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k *apiKey) report() keyUsageReport {
+	report := keyUsageReport{
+		Name:     k.name,
+		Tier:     k.tier,
+		Groups:   k.groups,
+		Requests: k.usage.requests.Load(),
+		Rejected: k.usage.rejected.Load(),
+		Limited:  k.usage.limited.Load(),
+	}
+
+	if lastUsed := k.usage.lastUsed.Load(); lastUsed != 0 {
+		t := time.Unix(lastUsed, 0).UTC()
+		report.LastUsed = &t
+	}
+
+	if report.Groups == nil {
+		report.Groups = []string{}
+	}
+
+	return report
+}
+
+// keyring holds the API keys by their SHA-256, so looking a key up doesn't compare it byte by byte.
+type keyring struct {
+	env        *env.Env
+	header     string
+	queryParam string
+	keys       map[[sha256.Size]byte]*apiKey
+}
+
+func newKeyring(env *env.Env) (*keyring, error) {
+	cfg := env.Cfg.APIKeys
+
+	keys := cfg.Keys
+	if cfg.File != "" {
+		fileKeys, err := loadAPIKeys(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(append([]config.APIKey{}, keys...), fileKeys...)
+	}
+
+	ring := &keyring{
+		env:        env,
+		header:     cfg.Header,
+		queryParam: cfg.QueryParam,
+		keys:       make(map[[sha256.Size]byte]*apiKey, len(keys)),
+	}
+
+	names := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.Name == "" {
+			return nil, errors.New("API keys must have a name")
+		}
+
+		if names[key.Name] {
+			return nil, fmt.Errorf("duplicate API key name %s", key.Name)
+		}
+
+		names[key.Name] = true
+
+		if len(key.Key) < minAPIKeyLength {
+			return nil, fmt.Errorf("API key %s must be at least %d characters long", key.Name, minAPIKeyLength)
+		}
+
+		if _, exists := env.Cfg.RateLimit.Tiers[key.Tier]; !exists {
+			return nil, fmt.Errorf("API key %s has an unknown tier %q", key.Name, key.Tier)
+		}
+
+		var groups []string
+		for _, group := range key.Groups {
+			if !strings.HasPrefix(group, "/") {
+				return nil, fmt.Errorf("group %q of API key %s must start with /", group, key.Name)
+			}
+
+			groups = append(groups, strings.TrimSuffix(group, "/"))
+		}
+
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, exists := ring.keys[hash]; exists {
+			return nil, fmt.Errorf("API key %s is repeated", key.Name)
+		}
+
+		ring.keys[hash] = &apiKey{name: key.Name, tier: key.Tier, groups: groups}
+	}
+
+	return ring, nil
+}
+
+func loadAPIKeys(path string) ([]config.APIKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open API keys file")
+	}
+	defer file.Close()
+
+	var keys []config.APIKey
+	err = json.NewDecoder(file).Decode(&keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode API keys file")
+	}
+
+	return keys, nil
+}
+
+func (k *keyring) lookup(raw string) *apiKey {
+	return k.keys[sha256.Sum256([]byte(raw))]
+}
+
+// middleware identifies the client by its API key, if any, and checks it can access the route. Requests without a key
+// are anonymous, while an unknown key is rejected instead of silently downgraded.
+func (k *keyring) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(k.header)
+		if raw == "" && k.queryParam != "" {
+			raw = c.Query(k.queryParam)
+		}
+
+		if raw == "" {
+			return
+		}
+
+		key := k.lookup(raw)
+		if key == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "invalid API key",
+			})
+
+			k.env.Log(c).Trace("invalid API key")
+			return
+		}
+
+		key.usage.requests.Add(1)
+		key.usage.lastUsed.Store(time.Now().Unix())
+
+		if !key.allows(unversionedPath(c)) {
+			key.usage.rejected.Add(1)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": "the API key can't access this route",
+			})
+
+			k.env.Log(c).Tracef("route not allowed for API key %s", key.name)
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+	}
+}
+
+// requestAPIKey returns the key of the request, or nil if anonymous.
+func requestAPIKey(c *gin.Context) *apiKey {
+	value, exists := c.Get(apiKeyContextKey)
+	if !exists {
+		return nil
+	}
+
+	return value.(*apiKey)
+}
+
+// usageHandler reports the usage of the key making the request.
+func usageHandler(env *env.Env) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := requestAPIKey(c)
+		if key == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "an API key is required",
+			})
+
+			env.Log(c).Trace("no API key")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   key.report(),
+		})
+
+		env.Log(c).Trace("ok")
+	}
+}
+
+// redactedURI hides the API key of the query, so it isn't logged.
+func redactedURI(c *gin.Context, queryParam string) string {
+	if queryParam == "" || c.Request.URL.Query().Get(queryParam) == "" {
+		return c.Request.URL.RequestURI()
+	}
+
+	query := c.Request.URL.Query()
+	query.Set(queryParam, "REDACTED")
+
+	return c.Request.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	officeKey  = "office-0123456789abcdef"
+	weatherKey = "weather-0123456789abcdef"
+)
+
+func withAPIKeys(keys ...config.APIKey) config.Option {
+	return func(cfg *config.Config) *config.Config {
+		cfg.RateLimit.Default = "2-M"
+		cfg.RateLimit.Tiers = map[string]config.Tier{
+			"partner": {Default: "5-M"},
+		}
+
+		cfg.APIKeys.Keys = keys
+		return cfg
+	}
+}
+
+func getWithKey(server *Server, path, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+
+	server.engine.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestAPIKeyTiers(t *testing.T) {
+	server, err := NewServer(withAPIKeys(config.APIKey{Key: officeKey, Name: "office", Tier: "partner"}))
+	require.NoError(t, err)
+
+	// Clients behind the same IP get their own quota with a key
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, getWithKey(server, "/ping", "").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, getWithKey(server, "/ping", "").Code)
+
+	w := getWithKey(server, "/ping", officeKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+
+	// Also accepted in the query
+	assert.Equal(t, http.StatusOK, get(server, "/ping?api_key="+officeKey).Code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, getWithKey(server, "/ping", officeKey).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, getWithKey(server, "/ping", officeKey).Code)
+}
+
+func TestAPIKeyAccess(t *testing.T) {
+	server, err := NewServer(withAPIKeys(
+		config.APIKey{Key: weatherKey, Name: "weather", Tier: "partner", Groups: []string{"/weather/"}},
+	))
+	require.NoError(t, err)
+
+	w := getWithKey(server, "/ping", "not-a-valid-key-at-all")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"status": "error", "message": "invalid API key"}`, w.Body.String())
+
+	w = getWithKey(server, "/v1/rut/digit?rut=11111111", weatherKey)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = getWithKey(server, "/rut/digit?rut=11111111", weatherKey)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = getWithKey(server, "/v1/weather/stations/1/history", weatherKey)
+	assert.NotEqual(t, http.StatusForbidden, w.Code)
+
+	// Always allowed, whatever the groups of the key
+	w = getWithKey(server, "/v1/keys/usage", weatherKey)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data keyUsageReport `json:"data"`
+	}
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "weather", body.Data.Name)
+	assert.Equal(t, "partner", body.Data.Tier)
+	assert.Equal(t, []string{"/weather"}, body.Data.Groups)
+	assert.Equal(t, int64(4), body.Data.Requests)
+	assert.Equal(t, int64(2), body.Data.Rejected)
+	assert.NotNil(t, body.Data.LastUsed)
+
+	assert.Equal(t, http.StatusUnauthorized, getWithKey(server, "/v1/keys/usage", "").Code)
+}
+
+func TestNewKeyring(t *testing.T) {
+	valid := config.APIKey{Key: officeKey, Name: "office", Tier: "partner"}
+
+	tests := []struct {
+		name string
+		keys []config.APIKey
+	}{
+		{"no name", []config.APIKey{{Key: officeKey, Tier: "partner"}}},
+		{"short key", []config.APIKey{{Key: "short", Name: "office", Tier: "partner"}}},
+		{"unknown tier", []config.APIKey{{Key: officeKey, Name: "office", Tier: "gold"}}},
+		{"anonymous tier", []config.APIKey{{Key: officeKey, Name: "office"}}},
+		{"relative group", []config.APIKey{{Key: officeKey, Name: "office", Tier: "partner", Groups: []string{"rut"}}}},
+		{"repeated name", []config.APIKey{valid, {Key: weatherKey, Name: "office", Tier: "partner"}}},
+		{"repeated key", []config.APIKey{valid, {Key: officeKey, Name: "other", Tier: "partner"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewServer(withAPIKeys(tt.keys...))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAPIKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"key": "`+weatherKey+`", "name": "weather", "tier": "partner"}]`), 0600))
+
+	server, err := NewServer(withAPIKeys(config.APIKey{Key: officeKey, Name: "office", Tier: "partner"}),
+		func(cfg *config.Config) *config.Config {
+			cfg.APIKeys.File = path
+			return cfg
+		})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, getWithKey(server, "/ping", officeKey).Code)
+	assert.Equal(t, http.StatusOK, getWithKey(server, "/ping", weatherKey).Code)
+}
+
+func TestAPIKeyNotLogged(t *testing.T) {
+	server, err := NewServer(withAPIKeys(config.APIKey{Key: officeKey, Name: "office", Tier: "partner"}))
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	server.env.Logger.SetOutput(&logs)
+	server.env.Logger.SetLevel(logrus.InfoLevel)
+
+	assert.Equal(t, http.StatusOK, get(server, "/ping?api_key="+officeKey).Code)
+	assert.Contains(t, logs.String(), "/ping?api_key=REDACTED")
+	assert.NotContains(t, logs.String(), officeKey)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/chenyahui/gin-cache"
+	"github.com/gin-gonic/gin"
+)
+
+// perRequestHeaders are set by the middlewares for each request, so a cached response must never replay them.
+func perRequestHeaders(cfg *config.Config) []string {
+	return append([]string{cfg.Log.RequestIDHeader}, rateLimitHeaders...)
+}
+
+// skipStale keeps responses built from stale data out of the cache, so clients get fresh data as soon as the source
+// recovers. Handlers attach the upstream.StaleError to the request, and the cache doesn't store aborted responses. It
+// must be used after the cache.
+func skipStale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		for _, err := range c.Errors {
+			if _, stale := upstream.Staleness(err.Err); stale {
+				c.Abort()
+				return
+			}
+		}
+	}
+}
+
+// keepHeaders replies from the cache with the values the current request has for headers, instead of the ones stored
+// along the response. The cached response is shared by every hit, so it's left untouched.
+func keepHeaders(headers []string) cache.Option {
+	return cache.WithBeforeReplyWithCache(func(c *gin.Context, _ *cache.ResponseCache) {
+		current := make(http.Header, len(headers))
+		for _, name := range headers {
+			if values := c.Writer.Header().Values(name); len(values) != 0 {
+				current[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+
+		c.Writer = &headerKeepingWriter{ResponseWriter: c.Writer, headers: headers, current: current}
+	})
+}
+
+// headerKeepingWriter restores the headers of the request right before they are written, as the cache sets the
+// stored ones after its callback runs.
+type headerKeepingWriter struct {
+	gin.ResponseWriter
+	headers []string
+	current http.Header
+}
+
+func (w *headerKeepingWriter) restore() {
+	for _, name := range w.headers {
+		w.Header().Del(name)
+	}
+
+	for name, values := range w.current {
+		w.Header()[name] = values
+	}
+}
+
+func (w *headerKeepingWriter) WriteHeaderNow() {
+	w.restore()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *headerKeepingWriter) Write(data []byte) (int, error) {
+	w.restore()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *headerKeepingWriter) WriteString(s string) (int, error) {
+	w.restore()
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/chenyahui/gin-cache"
+	"github.com/chenyahui/gin-cache/persist"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleNotCached(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	stale := true
+
+	engine := gin.New()
+	engine.Use(cache.CacheByRequestURI(persist.NewMemoryStore(time.Minute), time.Minute), skipStale())
+	engine.GET("/data", func(c *gin.Context) {
+		calls++
+
+		var err error
+		if stale {
+			err = &upstream.StaleError{Age: time.Minute, Err: errors.New("server is on fire")}
+			_ = c.Error(err)
+		}
+
+		c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{"calls": calls}, err))
+	})
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+		engine.ServeHTTP(w, req)
+
+		return w.Body.String()
+	}
+
+	assert.Contains(t, get(), `"stale":true`)
+
+	// The source recovered
+	stale = false
+	assert.Equal(t, `{"calls":2}`, get())
+
+	// Fresh responses are cached as usual
+	assert.Equal(t, `{"calls":2}`, get())
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed docs
+var docsFiles embed.FS
+
+// docsHandler serves the documentation page, which renders the OpenAPI document in the browser.
+func docsHandler() gin.HandlerFunc {
+	index, err := docsFiles.ReadFile("docs/index.html")
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+	}
+}
+
+// docsAssetHandler serves the scripts and styles of the documentation page.
+func docsAssetHandler() gin.HandlerFunc {
+	assets, err := fs.Sub(docsFiles, "docs")
+	if err != nil {
+		panic(err)
+	}
+
+	fileSystem := http.FS(assets)
+
+	return func(c *gin.Context) {
+		c.FileFromFS(c.Param("file"), fileSystem)
+	}
+}
@@ -0,0 +1,219 @@
+"use strict";
+
+// Texts of the page itself, the ones of the API come from the x-*-es extensions of the OpenAPI document
+const texts = {
+    es: {
+        loading: "Cargando la documentación…",
+        loadError: "No fue posible cargar la documentación",
+        parameters: "Parámetros",
+        name: "Nombre",
+        location: "Ubicación",
+        description: "Descripción",
+        value: "Valor",
+        tryIt: "Probar",
+        missing: "Falta el parámetro obligatorio",
+        deprecated: "obsoleta, usa la ruta con versión",
+        apiKey: "API key (opcional)",
+    },
+    en: {
+        loading: "Loading the documentation…",
+        loadError: "Unable to load the documentation",
+        parameters: "Parameters",
+        name: "Name",
+        location: "In",
+        description: "Description",
+        value: "Value",
+        tryIt: "Try it",
+        missing: "Missing required parameter",
+        deprecated: "deprecated, use the versioned route",
+        apiKey: "API key (optional)",
+    },
+};
+
+let spec = null;
+let lang = localStorage.getItem("lang") || (navigator.language.startsWith("es") ? "es" : "en");
+
+function text(key) {
+    return texts[lang][key];
+}
+
+// localized picks the Spanish extension of a field when available
+function localized(object, field) {
+    if (lang === "es" && object["x-" + field + "-es"]) {
+        return object["x-" + field + "-es"];
+    }
+
+    return object[field] || "";
+}
+
+function element(tag, attributes, ...children) {
+    const node = document.createElement(tag);
+    for (const [name, value] of Object.entries(attributes || {})) {
+        node.setAttribute(name, value);
+    }
+
+    for (const child of children) {
+        node.append(child);
+    }
+
+    return node;
+}
+
+function render() {
+    document.documentElement.lang = lang;
+    for (const button of document.querySelectorAll("nav button")) {
+        button.classList.toggle("active", button.dataset.lang === lang);
+    }
+
+    document.getElementById("api-key").placeholder = text("apiKey");
+
+    const main = document.getElementById("operations");
+    main.replaceChildren();
+
+    if (spec === null) {
+        main.append(element("p", {}, text("loading")));
+        return;
+    }
+
+    document.getElementById("description").textContent = localized(spec.info, "description");
+
+    const byTag = new Map();
+    for (const [path, item] of Object.entries(spec.paths)) {
+        for (const [method, operation] of Object.entries(item)) {
+            const tag = operation.tags[0];
+            if (!byTag.has(tag)) {
+                byTag.set(tag, []);
+            }
+
+            byTag.get(tag).push({path, method, operation});
+        }
+    }
+
+    for (const [tag, operations] of byTag) {
+        main.append(element("h2", {}, tag));
+        for (const entry of operations) {
+            main.append(renderOperation(entry));
+        }
+    }
+}
+
+function renderOperation({path, method, operation}) {
+    const body = element("div", {class: "operation"});
+
+    const description = localized(operation, "description");
+    if (description) {
+        body.append(element("p", {}, description));
+    }
+
+    const inputs = [];
+    const parameters = operation.parameters || [];
+    if (parameters.length !== 0) {
+        const rows = parameters.map((parameter) => {
+            const input = element("input", {type: "text", placeholder: parameter.schema.type});
+            inputs.push({parameter, input});
+
+            const name = element("td", {}, parameter.name);
+            if (parameter.required) {
+                name.append(element("span", {class: "required"}, " *"));
+            }
+
+            return element("tr", {}, name,
+                element("td", {}, parameter.in),
+                element("td", {}, localized(parameter, "description")),
+                element("td", {}, input));
+        });
+
+        body.append(element("h4", {}, text("parameters")),
+            element("table", {},
+                element("tr", {},
+                    element("th", {}, text("name")),
+                    element("th", {}, text("location")),
+                    element("th", {}, text("description")),
+                    element("th", {}, text("value"))),
+                ...rows));
+    }
+
+    const output = element("pre", {hidden: ""});
+    const button = element("button", {type: "button", class: "try"}, text("tryIt"));
+    button.addEventListener("click", () => tryOperation(path, method, inputs, output));
+
+    body.append(button, output);
+
+    const summary = element("summary", {},
+        element("span", {class: "method"}, method.toUpperCase()),
+        element("span", {class: "path"}, path),
+        localized(operation, "summary"));
+
+    if (operation.deprecated) {
+        summary.append(element("span", {class: "deprecated"}, " (" + text("deprecated") + ")"));
+    }
+
+    return element("details", operation.deprecated ? {class: "deprecated"} : {}, summary, body);
+}
+
+async function tryOperation(path, method, inputs, output) {
+    output.hidden = false;
+
+    const query = new URLSearchParams();
+    for (const {parameter, input} of inputs) {
+        const value = input.value.trim();
+        if (value === "") {
+            if (parameter.required) {
+                output.textContent = text("missing") + ": " + parameter.name;
+                return;
+            }
+
+            continue;
+        }
+
+        if (parameter.in === "path") {
+            path = path.replace("{" + parameter.name + "}", encodeURIComponent(value));
+        } else {
+            query.append(parameter.name, value);
+        }
+    }
+
+    const url = query.toString() === "" ? path : path + "?" + query;
+
+    try {
+        const headers = {};
+        const apiKey = document.getElementById("api-key").value.trim();
+        if (apiKey !== "") {
+            const scheme = spec.components.securitySchemes.apiKeyHeader;
+            headers[scheme.name] = apiKey;
+        }
+
+        const response = await fetch(url, {method: method.toUpperCase(), headers});
+        let content = await response.text();
+        try {
+            content = JSON.stringify(JSON.parse(content), null, 2);
+        } catch {
+            // Not JSON, shown as is
+        }
+
+        output.textContent = method.toUpperCase() + " " + url + "\n" + response.status + " " + response.statusText +
+            "\n\n" + content;
+    } catch (err) {
+        output.textContent = String(err);
+    }
+}
+
+for (const button of document.querySelectorAll("nav button")) {
+    button.addEventListener("click", () => {
+        lang = button.dataset.lang;
+        localStorage.setItem("lang", lang);
+        render();
+    });
+}
+
+render();
+
+fetch("/openapi.json")
+    .then((response) => response.json())
+    .then((openapi) => {
+        spec = openapi;
+        render();
+    })
+    .catch(() => {
+        document.getElementById("operations").replaceChildren(element("p", {}, text("loadError")));
+    });
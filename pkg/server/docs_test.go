@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocs(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	tests := []struct {
+		path        string
+		code        int
+		contentType string
+		contains    string
+	}{
+		{"/docs", http.StatusOK, "text/html", `src="/docs/docs.js"`},
+		{"/docs/docs.js", http.StatusOK, "javascript", "/openapi.json"},
+		{"/docs/docs.css", http.StatusOK, "text/css", "body"},
+		{"/docs/missing.js", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			server.engine.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), tt.contentType)
+			assert.Contains(t, w.Body.String(), tt.contains)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// validRequestID limits the IDs taken from callers, so they can't inject arbitrary content into the logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func newLogger(cfg config.Log) (*logrus.Logger, error) {
+	logger := logrus.New()
+
+	formatter, err := newLogFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.SetFormatter(formatter)
+
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid log level")
+	}
+
+	logger.SetLevel(level)
+
+	return logger, nil
+}
+
+func newLogFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "", "text":
+		return &logrus.TextFormatter{}, nil
+	case "json":
+		return &logrus.JSONFormatter{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+}
+
+// requestIDMiddleware identifies each request by the ID sent by the caller, or a new one if it's missing or invalid,
+// and sends it back so clients can refer to it.
+func requestIDMiddleware(header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(header)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Set(env.RequestIDKey, requestID)
+		c.Header(header, requestID)
+
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("http.request_id", requestID))
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// loggingMiddleware logs a line per request. Handlers attach the upstream errors they handled with c.Error, so they
+// are logged along the request that caused them.
+func loggingMiddleware(env *env.Env) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		entry := env.Log(c).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"route":      route,
+			"uri":        redactedURI(c, env.Cfg.APIKeys.QueryParam),
+			"status":     c.Writer.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip":  clientIP(env, c),
+		})
+
+		if len(c.Errors) != 0 {
+			entry = entry.WithField("upstream_error", strings.Join(c.Errors.Errors(), "; "))
+		}
+
+		entry.Info("request")
+	}
+}
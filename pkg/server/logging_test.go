@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withJSONLogs() config.Option {
+	return func(cfg *config.Config) *config.Config {
+		cfg.Log.Format = "json"
+		return cfg
+	}
+}
+
+func getWithRequestID(server *Server, path, requestID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "203.0.113.7:41234"
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+
+	server.engine.ServeHTTP(w, req)
+
+	return w
+}
+
+// logLines decodes the JSON lines written to logs with the given message.
+func logLines(t *testing.T, logs *bytes.Buffer, msg string) []map[string]any {
+	var lines []map[string]any
+
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+
+		if line["msg"] == msg {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	w := getWithRequestID(server, "/ping", "")
+	assert.Regexp(t, "^[0-9a-f]{32}$", w.Header().Get("X-Request-ID"))
+	assert.NotEqual(t, w.Header().Get("X-Request-ID"), getWithRequestID(server, "/ping", "").Header().Get("X-Request-ID"))
+
+	w = getWithRequestID(server, "/ping", "lb-7f3a:42")
+	assert.Equal(t, "lb-7f3a:42", w.Header().Get("X-Request-ID"))
+
+	w = getWithRequestID(server, "/ping", `"} injected`)
+	assert.Regexp(t, "^[0-9a-f]{32}$", w.Header().Get("X-Request-ID"))
+}
+
+func TestRequestIDNotCached(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	first := getWithRequestID(server, "/v1/rut/digit?rut=11111111", "aaaa-1")
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "aaaa-1", first.Header().Get("X-Request-ID"))
+
+	// Served from the cache
+	second := getWithRequestID(server, "/v1/rut/digit?rut=11111111", "bbbb-2")
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "bbbb-2", second.Header().Get("X-Request-ID"))
+	assert.Equal(t, []string{"bbbb-2"}, second.Header().Values("X-Request-ID"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+}
+
+func TestRequestLog(t *testing.T) {
+	server, err := NewServer(withJSONLogs())
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	server.env.Logger.SetOutput(&logs)
+
+	assert.Equal(t, http.StatusOK, getWithRequestID(server, "/v1/rut/digit?rut=11111111", "abc-123").Code)
+
+	lines := logLines(t, &logs, "request")
+	require.Len(t, lines, 1)
+
+	line := lines[0]
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, "abc-123", line["request_id"])
+	assert.Equal(t, "GET", line["method"])
+	assert.Equal(t, "/v1/rut/digit", line["route"])
+	assert.Equal(t, "/v1/rut/digit?rut=11111111", line["uri"])
+	assert.Equal(t, float64(http.StatusOK), line["status"])
+	assert.Equal(t, "203.0.113.7", line["client_ip"])
+	assert.Contains(t, line, "latency_ms")
+	assert.NotContains(t, line, "upstream_error")
+}
+
+func TestRequestLogUpstreamError(t *testing.T) {
+	server, err := NewServer(withJSONLogs())
+	require.NoError(t, err)
+
+	// Trip the breaker of the Banco Central, so the request fails without reaching it
+	for _, breaker := range server.breakers {
+		if breaker.Name() == "bcentral" {
+			for i := 0; i < server.env.Cfg.Upstream.BreakerThreshold; i++ {
+				breaker.Record(false)
+			}
+		}
+	}
+
+	var logs bytes.Buffer
+	server.env.Logger.SetOutput(&logs)
+
+	w := getWithRequestID(server, "/v1/economy/indicators", "abc-123")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	handled := logLines(t, bytes.NewBuffer(logs.Bytes()), "upstream unavailable")
+	require.Len(t, handled, 1)
+	assert.Equal(t, "abc-123", handled[0]["request_id"])
+	assert.Contains(t, handled[0]["error"], "circuit breaker for 'bcentral' is open")
+
+	lines := logLines(t, &logs, "request")
+	require.Len(t, lines, 1)
+	assert.Equal(t, "abc-123", lines[0]["request_id"])
+	assert.Contains(t, lines[0]["upstream_error"], "circuit breaker for 'bcentral' is open")
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Log
+		wantErr bool
+	}{
+		{"text", config.Log{Format: "text", Level: "info"}, false},
+		{"json", config.Log{Format: "json", Level: "trace"}, false},
+		{"unknown format", config.Log{Format: "xml", Level: "info"}, true},
+		{"unknown level", config.Log{Format: "json", Level: "loud"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newLogger(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/chenyahui/gin-cache"
+	"github.com/chenyahui/gin-cache/persist"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "libreapi"
+
+// metrics exported in the Prometheus format. Each server has its own registry. A nil *metrics is valid and records
+// nothing.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	fetchDuration   *prometheus.HistogramVec
+	fetchErrors     *prometheus.CounterVec
+	cacheLookups    *prometheus.CounterVec
+	cacheHits       *prometheus.CounterVec
+	rateLimited     *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Requests served by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve a request by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_fetch_duration_seconds",
+			Help:      "Time taken to fetch from an upstream source.",
+			Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"source"}),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_fetch_errors_total",
+			Help:      "Failed fetches from an upstream source by reason: unavailable, layout_changed or other.",
+		}, []string{"source", "reason"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_lookups_total",
+			Help:      "Requests looked up in the response cache by route group.",
+		}, []string{"group"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_hits_total",
+			Help:      "Requests served from the response cache by route group.",
+		}, []string{"group"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rate_limit_rejections_total",
+			Help:      "Requests rejected by the rate limiter by tier and quota.",
+		}, []string{"tier", "quota"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.fetchDuration,
+		m.fetchErrors,
+		m.cacheLookups,
+		m.cacheHits,
+		m.rateLimited,
+	)
+
+	return m
+}
+
+// middleware records every request, including the ones rejected by later middlewares.
+func (m *metrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.requests.WithLabelValues(route, c.Request.Method, status).Inc()
+		m.requestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// cache responds from the store by request URI like cache.CacheByRequestURI, counting the lookups and hits.
+func (m *metrics) cache(group string, store persist.CacheStore, expire time.Duration, opts ...cache.Option) gin.HandlerFunc {
+	if m == nil {
+		return cache.CacheByRequestURI(store, expire, opts...)
+	}
+
+	lookups := m.cacheLookups.WithLabelValues(group)
+	hits := m.cacheHits.WithLabelValues(group)
+
+	opts = append(opts, cache.WithOnHitCache(func(*gin.Context) {
+		hits.Inc()
+	}))
+
+	cached := cache.CacheByRequestURI(store, expire, opts...)
+
+	return func(c *gin.Context) {
+		lookups.Inc()
+		cached(c)
+	}
+}
+
+func (m *metrics) observeFetch(source string, latency time.Duration, err error) {
+	if upstream.Measurable(err) {
+		m.fetchDuration.WithLabelValues(source).Observe(latency.Seconds())
+	}
+
+	// The client gave up, which says nothing about the source
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	reason := "other"
+	if _, open := upstream.RetryAfter(err); open {
+		reason = "unavailable"
+	} else if upstream.IsLayoutChanged(err) {
+		reason = "layout_changed"
+	}
+
+	m.fetchErrors.WithLabelValues(source, reason).Inc()
+}
+
+func (m *metrics) rateLimit(tier, quota string) {
+	if m == nil {
+		return
+	}
+
+	if tier == anonymousTier {
+		tier = "anonymous"
+	}
+
+	m.rateLimited.WithLabelValues(tier, quota).Inc()
+}
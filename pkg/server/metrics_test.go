@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	server, err := NewServer(withRateLimit(config.RateLimit{
+		Default: "10-M",
+		Routes:  map[string]string{"/rut/digit": "2-M"},
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, get(server, "/v1/rut/digit?rut=11111111").Code)
+	assert.Equal(t, http.StatusOK, get(server, "/v1/rut/digit?rut=11111111").Code)
+	assert.Equal(t, http.StatusNotFound, get(server, "/missing").Code)
+
+	assert.Equal(t, http.StatusTooManyRequests, get(server, "/v1/rut/digit?rut=11111111").Code)
+
+	var layoutErr error = &upstream.LayoutError{Source: "SII", Mismatches: []string{"missing table"}}
+	fetch := server.monitor.Start("SII")
+	fetch.Done(&layoutErr)
+
+	w := get(server, "/metrics")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body := w.Body.String()
+	assert.Contains(t, body, `libreapi_http_requests_total{method="GET",route="/v1/rut/digit",status="200"} 2`)
+	assert.Contains(t, body, `libreapi_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `libreapi_cache_lookups_total{group="rut"} 2`)
+	assert.Contains(t, body, `libreapi_cache_hits_total{group="rut"} 1`)
+	assert.Contains(t, body, `libreapi_rate_limit_rejections_total{quota="/rut/digit",tier="anonymous"} 1`)
+	assert.Contains(t, body, `libreapi_upstream_fetch_duration_seconds_count{source="SII"} 1`)
+	assert.Contains(t, body, `libreapi_upstream_fetch_errors_total{reason="layout_changed",source="SII"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
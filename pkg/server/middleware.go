@@ -1,46 +1,65 @@
 package server
 
 import (
-	"time"
-
 	"github.com/ccuetoh/libreapi/pkg"
-	"github.com/ccuetoh/libreapi/pkg/env"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/newrelic/go-agent/v3/integrations/nrgin"
-	"github.com/ulule/limiter/v3"
-	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
-	"github.com/ulule/limiter/v3/drivers/store/memory"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/propagation"
 )
 
-func setupMiddlewares(server *Server) {
+func setupMiddlewares(server *Server) error {
+	store, client, err := newRateLimitStore(server.env.Cfg.RateLimit)
+	if err != nil {
+		return err
+	}
+
+	server.redis = client
+
+	limiter, err := newRateLimiter(server.env, store, server.metrics)
+	if err != nil {
+		return errors.Wrap(err, "invalid rate limits")
+	}
+
+	keys, err := newKeyring(server.env)
+	if err != nil {
+		return errors.Wrap(err, "invalid API keys")
+	}
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders(server.env.Cfg.APIKeys.Header, server.env.Cfg.Log.RequestIDHeader)
+	corsConfig.AddExposeHeaders(server.env.Cfg.Log.RequestIDHeader)
+
 	server.engine.Use(gin.Recovery())
 
 	if server.env.NewRelic != nil {
 		server.engine.Use(nrgin.Middleware(server.env.NewRelic))
 	}
 
+	if server.tracing != nil {
+		// Continues the trace of callers sending a traceparent header, such as a traced proxy
+		server.engine.Use(otelgin.Middleware(server.env.Cfg.Tracing.ServiceName,
+			otelgin.WithTracerProvider(server.tracing),
+			otelgin.WithPropagators(propagation.TraceContext{})),
+			redactSpanMiddleware(server.env.Cfg.APIKeys.QueryParam))
+	}
+
 	server.engine.Use(
+		requestIDMiddleware(server.env.Cfg.Log.RequestIDHeader),
+		server.metrics.middleware(),
 		loggingMiddleware(server.env),
 		gzip.Gzip(gzip.DefaultCompression),
-		cors.Default(),
+		cors.New(corsConfig),
 		serverInfoMiddleware(),
-		rateLimiterMiddleware(server.env.Cfg.HTTP.ProxyClientIPHeader))
-}
+		keys.middleware(),
+		limiter.middleware())
 
-func loggingMiddleware(env *env.Env) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Next()
-
-		ip := c.ClientIP()
-		if env.Cfg.HTTP.ProxyClientIPHeader != "" {
-			ip = c.GetHeader(env.Cfg.HTTP.ProxyClientIPHeader)
-		}
-
-		env.Log(c).Infof("%s %d %s %s", c.Request.Method, c.Writer.Status(), ip, c.Request.RequestURI)
-	}
+	return nil
 }
 
 func serverInfoMiddleware() gin.HandlerFunc {
@@ -48,23 +67,3 @@ func serverInfoMiddleware() gin.HandlerFunc {
 		c.Header("Server", libreapi.Descriptor)
 	}
 }
-
-func rateLimiterMiddleware(ipHeader string) gin.HandlerFunc {
-	rate := limiter.Rate{
-		Period: time.Hour,
-		Limit:  1500,
-	}
-
-	store := memory.NewStore()
-	instance := limiter.New(store, rate)
-
-	if ipHeader == "" {
-		return mgin.NewMiddleware(instance)
-
-	}
-
-	return mgin.NewMiddleware(instance, mgin.WithKeyGetter(
-		func(c *gin.Context) string {
-			return c.GetHeader(ipHeader)
-		}))
-}
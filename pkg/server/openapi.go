@@ -0,0 +1,686 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/economy"
+	"github.com/ccuetoh/libreapi/pkg/rut"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+	"github.com/ccuetoh/libreapi/pkg/weather"
+
+	"github.com/gin-gonic/gin"
+)
+
+// operation documents a route registered in addEndpoints. The schema of the response data is reflected from the
+// types of the values in data, with more than one value meaning any of them might be returned. Texts are given in
+// English and Spanish, the latter served as x-*-es extensions.
+type operation struct {
+	method string
+	// version is the API version prefixing the path, if any
+	version       string
+	path          string
+	tag           string
+	summary       string
+	summaryES     string
+	description   string
+	descriptionES string
+	params        []parameter
+	data          []any
+	contentType   string
+
+	// upstream operations depend on a scraped source, so they might serve stale data or fail because of it
+	upstream    bool
+	keyRequired bool
+	notFound    bool
+	units       bool
+	paginated   bool
+}
+
+type parameter struct {
+	name          string
+	in            string
+	kind          string
+	description   string
+	descriptionES string
+	required      bool
+}
+
+// Response shapes built with gin.H in the handlers
+type (
+	generatedRUT struct {
+		RUT    string `json:"rut"`
+		VD     string `json:"vd"`
+		Digits string `json:"digits"`
+	}
+
+	rutValidation struct {
+		Valid bool   `json:"valid"`
+		RUT   string `json:"rut"`
+	}
+
+	rutDigit struct {
+		Digit string `json:"digit"`
+		RUT   string `json:"rut"`
+	}
+
+	rutActivities struct {
+		RUT        string          `json:"rut"`
+		Name       string          `json:"name"`
+		Activities []*rut.Activity `json:"activities"`
+	}
+
+	statusReport struct {
+		Breakers []upstream.BreakerStatus `json:"breakers"`
+		Sources  []upstream.SourceStatus  `json:"sources"`
+	}
+)
+
+var unitsParam = parameter{name: "units", in: "query", kind: "string",
+	description:   "Unit system of the values: metric (default), imperial or si",
+	descriptionES: "Sistema de unidades de los valores: metric (por defecto), imperial o si"}
+
+var codeParam = parameter{name: "code", in: "path", kind: "integer", required: true,
+	description:   "Station code",
+	descriptionES: "Código de la estación"}
+
+var operations = []operation{
+	{
+		method: http.MethodGet, path: "/ping", tag: "status",
+		summary:   "Liveness check, answers pong in plain text",
+		summaryES: "Verifica que la API esté en línea, responde pong en texto plano",
+	},
+	{
+		method: http.MethodGet, path: "/status", tag: "status",
+		summary:   "Circuit breakers and health of each scraped source",
+		summaryES: "Estado de los circuit breakers y de cada fuente consultada",
+		data:      []any{statusReport{}},
+	},
+	{
+		method: http.MethodGet, path: "/metrics", tag: "status",
+		summary:   "Metrics in the Prometheus text format",
+		summaryES: "Métricas en el formato de texto de Prometheus",
+	},
+	{
+		method: http.MethodGet, path: "/openapi.json", tag: "status",
+		summary:   "This OpenAPI document",
+		summaryES: "Este documento OpenAPI",
+		data:      []any{map[string]any{}},
+	},
+	{
+		method: http.MethodGet, path: "/docs", tag: "status",
+		summary:     "Interactive documentation of the API",
+		summaryES:   "Documentación interactiva de la API",
+		contentType: "text/html",
+	},
+	{
+		method: http.MethodGet, path: "/docs/:file", tag: "status",
+		summary:   "Scripts and styles of the documentation",
+		summaryES: "Scripts y estilos de la documentación",
+		params: []parameter{
+			{name: "file", in: "path", kind: "string", required: true,
+				description:   "Asset name",
+				descriptionES: "Nombre del archivo"},
+		},
+		notFound: true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/keys/usage", tag: "keys",
+		summary:     "Usage of the API key making the request since the server started",
+		summaryES:   "Uso de la API key que hace la solicitud desde que inició el servidor",
+		data:        []any{keyUsageReport{}},
+		keyRequired: true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/rut/random", tag: "rut",
+		summary:   "Generate a random valid RUT",
+		summaryES: "Genera un RUT válido al azar",
+		params: []parameter{
+			{name: "min", in: "query", kind: "integer",
+				description:   "Lowest RUT number, 500000 by default",
+				descriptionES: "Número de RUT mínimo, 500000 por defecto"},
+			{name: "max", in: "query", kind: "integer",
+				description:   "Highest RUT number, 25000000 by default",
+				descriptionES: "Número de RUT máximo, 25000000 por defecto"},
+		},
+		data: []any{generatedRUT{}},
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/rut/validate", tag: "rut",
+		summary:   "Check the verification digit of a RUT",
+		summaryES: "Verifica el dígito verificador de un RUT",
+		params: []parameter{
+			{name: "rut", in: "query", kind: "string", required: true,
+				description:   "RUT with its verification digit",
+				descriptionES: "RUT con su dígito verificador"},
+		},
+		data: []any{rutValidation{}},
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/rut/digit", tag: "rut",
+		summary:   "Calculate the verification digit of a RUT",
+		summaryES: "Calcula el dígito verificador de un RUT",
+		params: []parameter{
+			{name: "rut", in: "query", kind: "string", required: true,
+				description:   "RUT without its verification digit",
+				descriptionES: "RUT sin su dígito verificador"},
+		},
+		data: []any{rutDigit{}},
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/rut/activities", tag: "rut",
+		summary:   "Name and economic activities registered in the SII",
+		summaryES: "Nombre y actividades económicas registradas en el SII",
+		params: []parameter{
+			{name: "rut", in: "query", kind: "string", required: true,
+				description:   "RUT with its verification digit",
+				descriptionES: "RUT con su dígito verificador"},
+		},
+		data:     []any{rutActivities{}},
+		upstream: true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/economy/indicators", tag: "economy",
+		summary:   "Daily economic indicators published by the Banco Central",
+		summaryES: "Indicadores económicos diarios publicados por el Banco Central",
+		data:      []any{economy.Indicators{}},
+		upstream:  true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/economy/currencies", tag: "economy",
+		summary:   "Exchange rates published by the Banco Central",
+		summaryES: "Tipos de cambio publicados por el Banco Central",
+		params: []parameter{
+			{name: "name", in: "query", kind: "string",
+				description:   "Filter currencies by name",
+				descriptionES: "Filtra las divisas por nombre"},
+		},
+		data:     []any{[]economy.Currency{}},
+		upstream: true,
+		notFound: true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/weather/stations", tag: "weather",
+		summary:       "Current conditions of the meteochile weather stations",
+		summaryES:     "Condiciones actuales de las estaciones meteorológicas de meteochile",
+		description:   "Searching by code returns a single station, otherwise a list is returned.",
+		descriptionES: "Al buscar por código se entrega una sola estación, de lo contrario se entrega una lista.",
+		params: []parameter{
+			{name: "name", in: "query", kind: "string",
+				description:   "Fuzzy search by station name",
+				descriptionES: "Búsqueda aproximada por nombre de la estación"},
+			{name: "code", in: "query", kind: "integer",
+				description:   "Station code",
+				descriptionES: "Código de la estación"},
+			{name: "region", in: "query", kind: "string",
+				description:   "Region of the station",
+				descriptionES: "Región de la estación"},
+			{name: "commune", in: "query", kind: "string",
+				description:   "Commune of the station",
+				descriptionES: "Comuna de la estación"},
+			{name: "operational", in: "query", kind: "boolean",
+				description:   "Whether the station is operational",
+				descriptionES: "Si la estación está operativa"},
+			{name: "min_temperature", in: "query", kind: "number",
+				description:   "Lowest current temperature",
+				descriptionES: "Temperatura actual mínima"},
+			{name: "max_temperature", in: "query", kind: "number",
+				description:   "Highest current temperature",
+				descriptionES: "Temperatura actual máxima"},
+			{name: "reported_within", in: "query", kind: "integer",
+				description:   "Reported in the last minutes",
+				descriptionES: "Con reporte en los últimos minutos"},
+			{name: "sort", in: "query", kind: "string",
+				description:   "code, name, temperature, humidity, pressure or last_report, prefixed by - for descending order",
+				descriptionES: "code, name, temperature, humidity, pressure o last_report, con el prefijo - para orden descendente"},
+			{name: "page", in: "query", kind: "integer",
+				description:   "Page number, starting at 1",
+				descriptionES: "Número de página, partiendo en 1"},
+			{name: "per_page", in: "query", kind: "integer",
+				description:   "Stations per page",
+				descriptionES: "Estaciones por página"},
+			unitsParam,
+		},
+		data:      []any{[]weather.ClimateStation{}, weather.ClimateStation{}},
+		upstream:  true,
+		notFound:  true,
+		units:     true,
+		paginated: true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/weather/stations/nearest", tag: "weather",
+		summary:   "Stations nearest to a location",
+		summaryES: "Estaciones más cercanas a una ubicación",
+		params: []parameter{
+			{name: "lat", in: "query", kind: "number", required: true,
+				description:   "Latitude",
+				descriptionES: "Latitud"},
+			{name: "lon", in: "query", kind: "number", required: true,
+				description:   "Longitude",
+				descriptionES: "Longitud"},
+			{name: "limit", in: "query", kind: "integer",
+				description:   "Amount of stations, 5 by default",
+				descriptionES: "Cantidad de estaciones, 5 por defecto"},
+			unitsParam,
+		},
+		data:     []any{[]weather.StationDistance{}},
+		upstream: true,
+		units:    true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/weather/stations/:code/history", tag: "weather",
+		summary:   "Recorded observations of a station aggregated by period",
+		summaryES: "Observaciones registradas de una estación agregadas por período",
+		params: []parameter{
+			codeParam,
+			{name: "from", in: "query", kind: "string",
+				description:   "RFC 3339 start, 24 hours before to by default",
+				descriptionES: "Inicio en formato RFC 3339, 24 horas antes de to por defecto"},
+			{name: "to", in: "query", kind: "string",
+				description:   "RFC 3339 end, now by default",
+				descriptionES: "Fin en formato RFC 3339, ahora por defecto"},
+			{name: "resolution", in: "query", kind: "string",
+				description:   "Aggregation period such as 15m, 1h or 24h, aligned to the time of Santiago",
+				descriptionES: "Período de agregación, como 15m, 1h o 24h, alineado a la hora de Santiago"},
+		},
+		data:     []any{[]weather.AggregatedObservation{}},
+		notFound: true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/weather/stations/:code/precipitation", tag: "weather",
+		summary:   "Accumulated rainfall of a station compared to its normal",
+		summaryES: "Precipitación acumulada de una estación comparada con su normal",
+		params:    []parameter{codeParam},
+		data:      []any{[]weather.PrecipitationWindow{}},
+		notFound:  true,
+	},
+	{
+		method: http.MethodGet, version: "v1", path: "/weather/summary", tag: "weather",
+		summary:   "Current conditions aggregated by region",
+		summaryES: "Condiciones actuales agregadas por región",
+		params:    []parameter{unitsParam},
+		data:      []any{[]weather.RegionSummary{}},
+		upstream:  true,
+		units:     true,
+	},
+}
+
+// openAPIHandler serves the document built from the operations.
+func openAPIHandler(cfg config.APIKeys) gin.HandlerFunc {
+	spec := buildOpenAPI(operations, cfg)
+
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+var pathParamRegexp = regexp.MustCompile(`:([^/]+)`)
+
+// openAPIPath converts a gin route path into an OpenAPI one.
+func openAPIPath(path string) string {
+	return pathParamRegexp.ReplaceAllString(path, "{$1}")
+}
+
+func buildOpenAPI(operations []operation, cfg config.APIKeys) map[string]any {
+	schemas := newSchemaBuilder()
+	paths := make(map[string]any)
+
+	for _, op := range operations {
+		for i, route := range op.routes() {
+			item, exists := paths[openAPIPath(route)].(map[string]any)
+			if !exists {
+				item = make(map[string]any)
+				paths[openAPIPath(route)] = item
+			}
+
+			item[strings.ToLower(op.method)] = op.build(schemas, route, i != 0)
+		}
+	}
+
+	schemas.schemas["Error"] = map[string]any{
+		"type":     "object",
+		"required": []string{"status"},
+		"properties": map[string]any{
+			"status":  map[string]any{"type": "string", "enum": []string{"fail", "error"}},
+			"errors":  map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+			"data":    map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+			"message": map[string]any{"type": "string"},
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":            "LibreAPI",
+			"description":      "Free API for Chilean data: RUT, economic indicators and weather",
+			"x-description-es": "API libre con datos de Chile: RUT, indicadores económicos y clima",
+			"version":          "1",
+		},
+		"paths": paths,
+		// Keys are optional, anonymous clients get the default quotas
+		"security": []any{
+			map[string]any{},
+			map[string]any{"apiKeyHeader": []string{}},
+			map[string]any{"apiKeyQuery": []string{}},
+		},
+		"components": map[string]any{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]any{
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": cfg.Header},
+				"apiKeyQuery":  map[string]any{"type": "apiKey", "in": "query", "name": cfg.QueryParam},
+			},
+		},
+	}
+}
+
+// routes returns the paths an operation is served at, the first one being the canonical. Operations of the
+// unversioned API version are also served without a prefix as deprecated aliases.
+func (op operation) routes() []string {
+	if op.version == "" {
+		return []string{op.path}
+	}
+
+	routes := []string{"/" + op.version + op.path}
+	if op.version == unversioned {
+		routes = append(routes, op.path)
+	}
+
+	return routes
+}
+
+func (op operation) build(schemas *schemaBuilder, route string, deprecated bool) map[string]any {
+	responses := map[string]any{
+		"200": op.successResponse(schemas),
+		"401": errorResponse("Invalid API key"),
+		"403": errorResponse("The API key can't access this route"),
+		"429": errorResponse("Rate limit exceeded, see the RateLimit-* headers"),
+	}
+
+	if len(op.params) != 0 {
+		responses["400"] = errorResponse("Invalid parameters")
+	}
+
+	if op.notFound {
+		responses["404"] = errorResponse("Nothing matched, data is null")
+	}
+
+	if op.upstream {
+		responses["500"] = errorResponse("Unable to fetch the data")
+		responses["502"] = errorResponse("The upstream source changed its layout or returned implausible data")
+		responses["503"] = errorResponse("The upstream source is unavailable, see the Retry-After header")
+	}
+
+	result := map[string]any{
+		"tags":         []string{op.tag},
+		"summary":      op.summary,
+		"x-summary-es": op.summaryES,
+		"operationId":  operationID(op.method, route),
+		"responses":    responses,
+	}
+
+	if op.description != "" {
+		result["description"] = op.description
+		result["x-description-es"] = op.descriptionES
+	}
+
+	if deprecated {
+		result["deprecated"] = true
+	}
+
+	if op.keyRequired {
+		result["security"] = []any{
+			map[string]any{"apiKeyHeader": []string{}},
+			map[string]any{"apiKeyQuery": []string{}},
+		}
+	}
+
+	if len(op.params) != 0 {
+		var params []any
+		for _, param := range op.params {
+			p := map[string]any{
+				"name":             param.name,
+				"in":               param.in,
+				"description":      param.description,
+				"x-description-es": param.descriptionES,
+				"required":         param.required,
+				"schema":           map[string]any{"type": param.kind},
+			}
+
+			params = append(params, p)
+		}
+
+		result["parameters"] = params
+	}
+
+	return result
+}
+
+func (op operation) successResponse(schemas *schemaBuilder) map[string]any {
+	if len(op.data) == 0 {
+		contentType := op.contentType
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+
+		return map[string]any{
+			"description": "OK",
+			"content": map[string]any{
+				contentType: map[string]any{"schema": map[string]any{"type": "string"}},
+			},
+		}
+	}
+
+	var data map[string]any
+	if len(op.data) == 1 {
+		data = schemas.schema(reflect.TypeOf(op.data[0]))
+	} else {
+		var options []any
+		for _, value := range op.data {
+			options = append(options, schemas.schema(reflect.TypeOf(value)))
+		}
+
+		data = map[string]any{"oneOf": options}
+	}
+
+	properties := map[string]any{
+		"status": map[string]any{"type": "string", "enum": []string{"success"}},
+		"data":   data,
+	}
+
+	if op.upstream {
+		properties["stale"] = map[string]any{"type": "boolean", "description": "Present when serving cached data"}
+		properties["age_seconds"] = map[string]any{"type": "integer", "description": "Age of the stale data"}
+	}
+
+	if op.units {
+		properties["units"] = schemas.schema(reflect.TypeOf(weather.Units{}))
+	}
+
+	if op.paginated {
+		properties["pagination"] = schemas.schema(reflect.TypeOf(weather.Pagination{}))
+	}
+
+	return map[string]any{
+		"description": "OK",
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{
+					"type":       "object",
+					"required":   []string{"status", "data"},
+					"properties": properties,
+				},
+			},
+		},
+	}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+			},
+		},
+	}
+}
+
+// operationID derives an ID such as getV1WeatherStationsCodeHistory from the method and path.
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' || r == '_' }) {
+		part = strings.TrimPrefix(part, ":")
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return id.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder reflects the JSON schema of Go types. Named structs are added to the components and referenced.
+type schemaBuilder struct {
+	schemas map[string]any
+	types   map[string]reflect.Type
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]any),
+		types:   make(map[string]reflect.Type),
+	}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(b.schema(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+
+		return b.reference(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *schemaBuilder) reference(t reflect.Type) map[string]any {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+
+	ref := map[string]any{"$ref": "#/components/schemas/" + string(name)}
+
+	existing, exists := b.types[string(name)]
+	if exists {
+		if existing != t {
+			panic(fmt.Sprintf("schema name %s used by both %v and %v", string(name), existing, t))
+		}
+
+		return ref
+	}
+
+	// Registered before building it so recursive types terminate
+	b.types[string(name)] = t
+	b.schemas[string(name)] = b.object(t)
+
+	return ref
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+
+	b.addFields(t, properties, &required)
+
+	result := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) != 0 {
+		result["required"] = required
+	}
+
+	return result
+}
+
+// addFields adds the JSON fields of a struct, flattening embedded structs like encoding/json does.
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties, required)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schema(field.Type)
+
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable marks a schema as accepting null. References can't have siblings, so they are wrapped.
+func nullable(schema map[string]any) map[string]any {
+	if _, isRef := schema["$ref"]; isRef {
+		return map[string]any{"allOf": []any{schema}, "nullable": true}
+	}
+
+	result := make(map[string]any, len(schema)+1)
+	for k, v := range schema {
+		result[k] = v
+	}
+
+	result["nullable"] = true
+
+	return result
+}
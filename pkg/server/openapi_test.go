@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	documented := make(map[string]bool)
+	for _, op := range operations {
+		for _, route := range op.routes() {
+			documented[op.method+" "+route] = true
+		}
+	}
+
+	registered := make(map[string]bool)
+	for _, route := range server.engine.Routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+
+		assert.True(t, documented[key], "route %s is not documented in the OpenAPI operations", key)
+	}
+
+	for key := range documented {
+		assert.True(t, registered[key], "documented operation %s is not registered", key)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	server.engine.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Contains(t, spec.Paths, "/v1/weather/stations/{code}/history")
+	assert.Equal(t, true, spec.Paths["/weather/stations/{code}/history"]["get"].(map[string]any)["deprecated"])
+	assert.NotContains(t, spec.Paths["/v1/weather/stations/{code}/history"]["get"], "deprecated")
+	assert.Contains(t, spec.Components.Schemas, "ClimateStation")
+
+	// Every reference must point to a defined schema
+	for _, ref := range strings.Split(w.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		assert.Contains(t, spec.Components.Schemas, name)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/weather/stations/{code}/history", openAPIPath("/weather/stations/:code/history"))
+	assert.Equal(t, "/weather/stations", openAPIPath("/weather/stations"))
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+const rateLimitPrefix = "libreapi:ratelimit"
+
+// quota limits the requests to the routes under a path prefix. Clients have a counter for each quota.
+type quota struct {
+	prefix  string
+	limiter *limiter.Limiter
+}
+
+type rateLimiter struct {
+	env     *env.Env
+	metrics *metrics
+	// Quotas of each tier sorted from the longest prefix to the shortest, the default quota being the last one.
+	// Anonymous clients use the anonymousTier
+	tiers map[string][]quota
+}
+
+const anonymousTier = ""
+
+func newRateLimiter(env *env.Env, store limiter.Store, metrics *metrics) (*rateLimiter, error) {
+	cfg := env.Cfg.RateLimit
+
+	anonymous, err := newQuotas(store, config.Tier{Default: cfg.Default, Routes: cfg.Routes})
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := map[string][]quota{anonymousTier: anonymous}
+	for name, tier := range cfg.Tiers {
+		if name == anonymousTier {
+			return nil, errors.New("tiers must have a name")
+		}
+
+		tiers[name], err = newQuotas(store, tier)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid tier %s", name)
+		}
+	}
+
+	return &rateLimiter{env: env, metrics: metrics, tiers: tiers}, nil
+}
+
+func newQuotas(store limiter.Store, tier config.Tier) ([]quota, error) {
+	rates := map[string]string{"/": tier.Default}
+	for prefix, rate := range tier.Routes {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("route %q must start with /", prefix)
+		}
+
+		rates[strings.TrimSuffix(prefix, "/")] = rate
+	}
+
+	var quotas []quota
+	for prefix, formatted := range rates {
+		rate, err := limiter.NewRateFromFormatted(formatted)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rate of %s", prefix)
+		}
+
+		quotas = append(quotas, quota{prefix: prefix, limiter: limiter.New(store, rate)})
+	}
+
+	sort.Slice(quotas, func(i, j int) bool {
+		return len(quotas[i].prefix) > len(quotas[j].prefix)
+	})
+
+	return quotas, nil
+}
+
+// newRateLimitStore keeps the counters in Redis if configured, so they are shared across replicas and survive
+// restarts, or in memory otherwise. The Redis client is nil when the counters are kept in memory.
+func newRateLimitStore(cfg config.RateLimit) (limiter.Store, *redis.Client, error) {
+	if cfg.RedisURL == "" {
+		return memory.NewStoreWithOptions(limiter.StoreOptions{
+			Prefix:          rateLimitPrefix,
+			CleanUpInterval: limiter.DefaultCleanUpInterval,
+		}), nil, nil
+	}
+
+	options, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid Redis URL")
+	}
+
+	client := redis.NewClient(options)
+
+	store, err := sredis.NewStoreWithOptions(client, limiter.StoreOptions{
+		Prefix:   rateLimitPrefix,
+		MaxRetry: limiter.DefaultMaxRetry,
+	})
+	if err != nil {
+		client.Close()
+		return nil, nil, errors.Wrap(err, "unable to connect to Redis")
+	}
+
+	return store, client, nil
+}
+
+// quotaFor returns the quota of the tier with the longest prefix matching whole segments of the path.
+func (r *rateLimiter) quotaFor(tier, path string) quota {
+	quotas := r.tiers[tier]
+	for _, q := range quotas {
+		if matchesPrefix(path, q.prefix) {
+			return q
+		}
+	}
+
+	return quotas[len(quotas)-1]
+}
+
+// matchesPrefix reports whether the path is under the prefix, matching whole segments.
+func matchesPrefix(path, prefix string) bool {
+	return prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// middleware counts the request against the quota of its route, by API key if one was given or by IP otherwise. If
+// the counters can't be reached the request is let through, so an outage of the store doesn't take down the API.
+func (r *rateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tier, subject := anonymousTier, "ip:"+clientIP(r.env, c)
+
+		key := requestAPIKey(c)
+		if key != nil {
+			tier, subject = key.tier, "key:"+key.name
+		}
+
+		q := r.quotaFor(tier, unversionedPath(c))
+
+		limit, err := q.limiter.Get(c.Request.Context(), tier+":"+q.prefix+":"+subject)
+		if err != nil {
+			r.env.Log(c).Errorf("unable to check rate limit: %v", err)
+			return
+		}
+
+		setRateLimitHeaders(c, q.limiter.Rate, limit)
+
+		if limit.Reached {
+			r.metrics.rateLimit(tier, q.prefix)
+			if key != nil {
+				key.usage.limited.Add(1)
+			}
+
+			c.Header("Retry-After", strconv.FormatInt(secondsUntil(limit.Reset), 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"status":  "error",
+				"message": "rate limit exceeded",
+			})
+
+			r.env.Log(c).Trace("rate limited")
+			return
+		}
+	}
+}
+
+// setRateLimitHeaders adds the RateLimit-* headers of the IETF draft, along with the X-RateLimit-* ones sent before.
+// rateLimitHeaders are set by setRateLimitHeaders, and Retry-After once the quota is reached
+var rateLimitHeaders = []string{
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+	"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+	"Retry-After",
+}
+
+func setRateLimitHeaders(c *gin.Context, rate limiter.Rate, limit limiter.Context) {
+	reset := secondsUntil(limit.Reset)
+
+	c.Header("RateLimit-Limit", strconv.FormatInt(limit.Limit, 10))
+	c.Header("RateLimit-Remaining", strconv.FormatInt(limit.Remaining, 10))
+	c.Header("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rate.Limit, int64(rate.Period/time.Second)))
+
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(limit.Limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(limit.Remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(limit.Reset, 10))
+}
+
+// secondsUntil returns the seconds left until the Unix time, at least 0.
+func secondsUntil(unix int64) int64 {
+	seconds := unix - time.Now().Unix()
+	if seconds < 0 {
+		return 0
+	}
+
+	return seconds
+}
+
+// unversionedPath returns the route of the request without the version prefix, so versions and their unversioned
+// aliases share quotas.
+func unversionedPath(c *gin.Context) string {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+
+	for _, version := range apiVersions {
+		if strings.HasPrefix(path, "/"+version.name+"/") {
+			return strings.TrimPrefix(path, "/"+version.name)
+		}
+	}
+
+	return path
+}
+
+func clientIP(env *env.Env, c *gin.Context) string {
+	if env.Cfg.HTTP.ProxyClientIPHeader != "" {
+		return c.GetHeader(env.Cfg.HTTP.ProxyClientIPHeader)
+	}
+
+	return c.ClientIP()
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+func withRateLimit(rateLimit config.RateLimit) config.Option {
+	return func(cfg *config.Config) *config.Config {
+		cfg.RateLimit = rateLimit
+		return cfg
+	}
+}
+
+func get(server *Server, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	server.engine.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	server, err := NewServer(withRateLimit(config.RateLimit{
+		Default: "3-M",
+		Routes:  map[string]string{"/rut/activities": "1-M"},
+	}))
+	require.NoError(t, err)
+
+	w := get(server, "/ping")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "3;w=60", w.Header().Get("RateLimit-Policy"))
+	assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+
+	// The activities quota is separate, and shared by the version and its alias
+	w = get(server, "/v1/rut/activities")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = get(server, "/rut/activities")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status": "error", "message": "rate limit exceeded"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusOK, get(server, "/v1/rut/digit?rut=11111111").Code)
+	assert.Equal(t, http.StatusOK, get(server, "/ping").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(server, "/ping").Code)
+}
+
+func TestRateLimitCached(t *testing.T) {
+	server, err := NewServer(withRateLimit(config.RateLimit{Default: "10-M"}))
+	require.NoError(t, err)
+
+	first := get(server, "/v1/rut/digit?rut=11111111")
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "9", first.Header().Get("RateLimit-Remaining"))
+
+	// Served from the cache, but still counted
+	second := get(server, "/v1/rut/digit?rut=11111111")
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "8", second.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "8", second.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, []string{"8"}, second.Header().Values("RateLimit-Remaining"))
+}
+
+func TestRateLimitRedis(t *testing.T) {
+	redis := miniredis.RunT(t)
+
+	rateLimit := config.RateLimit{
+		Default:  "2-H",
+		RedisURL: "redis://" + redis.Addr(),
+	}
+
+	// Replicas share the counters
+	first, err := NewServer(withRateLimit(rateLimit))
+	require.NoError(t, err)
+
+	second, err := NewServer(withRateLimit(rateLimit))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, get(first, "/ping").Code)
+	assert.Equal(t, http.StatusOK, get(second, "/ping").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(first, "/ping").Code)
+
+	assert.NotEmpty(t, redis.Keys())
+
+	// Requests go through if Redis is down
+	redis.Close()
+	assert.Equal(t, http.StatusOK, get(second, "/ping").Code)
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RateLimit
+		wantErr bool
+	}{
+		{"valid", config.RateLimit{Default: "10-S", Routes: map[string]string{"/weather": "5-M"}}, false},
+		{"invalid default", config.RateLimit{Default: "often"}, true},
+		{"invalid route", config.RateLimit{Default: "10-S", Routes: map[string]string{"/weather": "5"}}, true},
+		{"relative route", config.RateLimit{Default: "10-S", Routes: map[string]string{"weather": "5-M"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			environment := newTestEnv()
+			environment.Cfg.RateLimit = tt.cfg
+
+			_, err := newRateLimiter(environment, memory.NewStore(), nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestQuotaFor(t *testing.T) {
+	environment := newTestEnv()
+	environment.Cfg.RateLimit = config.RateLimit{
+		Default: "10-S",
+		Routes: map[string]string{
+			"/weather":                 "5-M",
+			"/weather/stations/:code/": "2-M",
+			"/rut/activities":          "1-M",
+		},
+	}
+
+	limiter, err := newRateLimiter(environment, memory.NewStore(), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/ping", "/"},
+		{"/rut/activities", "/rut/activities"},
+		{"/rut/activitiesx", "/"},
+		{"/weather/summary", "/weather"},
+		{"/weather/stations/:code/history", "/weather/stations/:code"},
+		{"/weathers", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, limiter.quotaFor(anonymousTier, tt.path).prefix)
+		})
+	}
+}
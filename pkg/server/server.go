@@ -1,44 +1,96 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ccuetoh/libreapi/pkg/config"
-	"github.com/ccuetoh/libreapi/pkg/economy"
 	"github.com/ccuetoh/libreapi/pkg/env"
-	"github.com/ccuetoh/libreapi/pkg/rut"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 	"github.com/ccuetoh/libreapi/pkg/weather"
 
-	"github.com/chenyahui/gin-cache"
-	"github.com/chenyahui/gin-cache/persist"
 	"github.com/gin-gonic/gin"
 	contextNrLogrus "github.com/newrelic/go-agent/v3/integrations/logcontext-v2/nrlogrus"
 	"github.com/newrelic/go-agent/v3/integrations/nrlogrus"
 	"github.com/newrelic/go-agent/v3/newrelic"
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// defaultFlushTimeout bounds the New Relic flush when shutting down without a deadline
+const defaultFlushTimeout = 10 * time.Second
+
 type Server struct {
-	engine *gin.Engine
-	env    *env.Env
+	engine    *gin.Engine
+	env       *env.Env
+	breakers  []*upstream.Breaker
+	monitor   *upstream.Monitor
+	metrics   *metrics
+	history   *weather.HistoryStore
+	collector *weather.Collector
+	notifier  *weather.Notifier
+
+	httpServer *http.Server
+	// Only set when serving HTTPS
+	certificates   *certificateLoader
+	redirectServer *http.Server
+	// Only set when the rate limits are kept in Redis
+	redis *redis.Client
+	// Only set when tracing is enabled
+	tracing *sdktrace.TracerProvider
+	// Background workers, such as the collector, run until stop is called
+	workersCtx context.Context
+	stop       context.CancelFunc
+	workers    sync.WaitGroup
 }
 
 func NewServer(cfgOpts ...config.Option) (*Server, error) {
 	cfg := config.Build(cfgOpts...)
-	logger := logrus.New()
+
+	var exporter sdktrace.SpanExporter
+	if cfg.Tracing.Endpoint != "" {
+		var err error
+		exporter, err = newSpanExporter(cfg.Tracing)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create trace exporter")
+		}
+	}
+
+	return newServer(cfg, exporter)
+}
+
+// newServer builds a server exporting its traces to exporter, or without tracing if it's nil.
+func newServer(cfg *config.Config, exporter sdktrace.SpanExporter) (*Server, error) {
+	// The collector refreshes the history on this interval, which must be positive for its ticker
+	if cfg.Weather.HistoryInterval <= 0 {
+		return nil, fmt.Errorf("the weather history interval must be positive, got %s", cfg.Weather.HistoryInterval)
+	}
+
+	// A breaker without a positive threshold would open on the first failure
+	if cfg.Upstream.BreakerThreshold <= 0 {
+		return nil, fmt.Errorf("the upstream breaker threshold must be positive, got %d", cfg.Upstream.BreakerThreshold)
+	}
+
+	logger, err := newLogger(cfg.Log)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid log configuration")
+	}
 
 	var newRelicApp *newrelic.Application
 	if cfg.NewRelic.Licence != "" {
-		var err error
 		newRelicApp, err = newNewRelic(cfg, logger)
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to create NewRelic instance")
 		}
 
-		formatter := contextNrLogrus.NewFormatter(newRelicApp, &logrus.TextFormatter{})
+		formatter := contextNrLogrus.NewFormatter(newRelicApp, logger.Formatter)
 		logger.SetFormatter(formatter)
 	}
 
@@ -49,16 +101,185 @@ func NewServer(cfgOpts ...config.Option) (*Server, error) {
 			Cfg:      cfg,
 			NewRelic: newRelicApp,
 		},
+		monitor: upstream.NewMonitor(),
+		metrics: newMetrics(),
+	}
+
+	server.monitor.Observe(server.metrics.observeFetch)
+
+	if exporter != nil {
+		server.tracing, err = newTracerProvider(cfg.Tracing, exporter)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to set up tracing")
+		}
+
+		server.env.TracerProvider = server.tracing
 	}
 
-	setupMiddlewares(server)
-	addEndpoints(server)
+	if cfg.Weather.HistoryPath != "" {
+		server.history, err = weather.OpenHistoryStore(cfg.Weather.HistoryPath, cfg.Weather.HistoryRetention)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open weather history")
+		}
+	} else {
+		server.history = weather.NewHistoryStore(cfg.Weather.HistoryRetention)
+	}
+
+	err = setupMiddlewares(server)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addEndpoints(server)
+	if err != nil {
+		return nil, err
+	}
+
+	server.httpServer = &http.Server{
+		Addr:              net.JoinHostPort(cfg.HTTP.Address, cfg.HTTP.Port),
+		Handler:           server.engine,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+
+	err = setupTLS(server)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid TLS configuration")
+	}
+
+	server.workersCtx, server.stop = context.WithCancel(context.Background())
 
 	return server, nil
 }
 
+func (s *Server) Config() *config.Config {
+	return s.env.Cfg
+}
+
+// Start runs the background workers and serves until Shutdown is called, in which case it returns nil.
 func (s *Server) Start() error {
-	return s.engine.Run(net.JoinHostPort(s.env.Cfg.HTTP.Address, s.env.Cfg.HTTP.Port))
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return errors.Wrap(err, "unable to listen")
+	}
+
+	if s.redirectServer != nil {
+		redirectListener, err := net.Listen("tcp", s.redirectServer.Addr)
+		if err != nil {
+			listener.Close()
+			return errors.Wrap(err, "unable to listen for HTTP redirects")
+		}
+
+		go func() {
+			err := s.redirectServer.Serve(redirectListener)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.env.Logger.Errorf("HTTP redirect server stopped: %v", err)
+			}
+		}()
+	}
+
+	s.runWorker(func(ctx context.Context) {
+		s.collector.Run(ctx, s.env.Cfg.Weather.HistoryInterval)
+	})
+
+	if s.notifier != nil {
+		s.runWorker(s.notifier.Run)
+	}
+
+	if s.certificates != nil && s.env.Cfg.HTTP.TLS.ReloadInterval > 0 {
+		s.runWorker(func(ctx context.Context) {
+			s.certificates.watch(ctx, s.env.Cfg.HTTP.TLS.ReloadInterval)
+		})
+	}
+
+	return s.serve(listener)
+}
+
+func (s *Server) serve(listener net.Listener) error {
+	var err error
+	if s.httpServer.TLSConfig != nil {
+		// The certificate comes from the TLS config, which reloads it
+		err = s.httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = s.httpServer.Serve(listener)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Server) runWorker(run func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		run(s.workersCtx)
+	}()
+}
+
+// Shutdown stops accepting connections and waits for the in-flight requests to finish, then stops the background
+// workers, closes the history and flushes New Relic. The context bounds how long to wait.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "unable to drain requests")
+	}
+
+	if s.redirectServer != nil {
+		redirectErr := s.redirectServer.Shutdown(ctx)
+		if redirectErr != nil && err == nil {
+			err = errors.Wrap(redirectErr, "unable to drain HTTP redirects")
+		}
+	}
+
+	s.stop()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		closeErr := s.history.Close()
+		if closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "unable to close weather history")
+		}
+	case <-ctx.Done():
+		if err == nil {
+			err = errors.Wrap(ctx.Err(), "background workers didn't stop")
+		}
+	}
+
+	if s.redis != nil {
+		closeErr := s.redis.Close()
+		if closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "unable to close Redis client")
+		}
+	}
+
+	if s.tracing != nil {
+		closeErr := s.tracing.Shutdown(ctx)
+		if closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "unable to flush traces")
+		}
+	}
+
+	if s.env.NewRelic != nil {
+		timeout := defaultFlushTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+
+		s.env.NewRelic.Shutdown(timeout)
+	}
+
+	return err
 }
 
 func newNewRelic(cfg *config.Config, logger *logrus.Logger) (*newrelic.Application, error) {
@@ -67,7 +288,6 @@ func newNewRelic(cfg *config.Config, logger *logrus.Logger) (*newrelic.Applicati
 		newrelic.ConfigLicense(cfg.NewRelic.Licence),
 		newrelic.ConfigAppLogForwardingEnabled(cfg.NewRelic.LogForwardingEnabled),
 		func(config *newrelic.Config) {
-			logger.SetLevel(logrus.InfoLevel)
 			config.Logger = nrlogrus.Transform(logger)
 		},
 	)
@@ -80,36 +300,103 @@ func newEngine(cfg *config.Config) *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	return gin.New()
-}
+	engine := gin.New()
+	// Handlers pass the gin.Context to the services, which need the deadline and trace of the request
+	engine.ContextWithFallback = true
 
-func addEndpoints(server *Server) {
-	store := persist.NewMemoryStore(time.Minute)
+	return engine
+}
 
+func addEndpoints(server *Server) error {
 	server.engine.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
-	rutHandler := rut.NewHandler(server.env, rut.NewDefaultService())
-	rutGroup := server.engine.Group("/rut")
+	server.engine.GET("/status", statusHandler(server))
+	server.engine.GET("/metrics", server.metrics.handler())
+	server.engine.GET("/openapi.json", openAPIHandler(server.env.Cfg.APIKeys))
+	server.engine.GET("/docs", docsHandler())
+	server.engine.GET("/docs/:file", docsAssetHandler())
+
+	deprecation, err := newDeprecation(server.env.Cfg.API)
+	if err != nil {
+		return errors.Wrap(err, "invalid API deprecation")
+	}
+
+	for _, version := range apiVersions {
+		mount, err := version.build(server)
+		if err != nil {
+			return errors.Wrapf(err, "unable to build API %s", version.name)
+		}
+
+		mount(server.engine.Group("/" + version.name))
+
+		if version.name == unversioned {
+			mount(server.engine.Group("", deprecation.middleware(version.name)))
+		}
+	}
+
+	return nil
+}
+
+func precipitationNormals(cfg map[string][]float64) (weather.PrecipitationNormals, error) {
+	normals := make(weather.PrecipitationNormals, len(cfg))
+	for key, monthly := range cfg {
+		code, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid station code %q", key)
+		}
+
+		if len(monthly) != 12 {
+			return nil, fmt.Errorf("station %d has %d monthly normals instead of 12", code, len(monthly))
+		}
+
+		normals[code] = *(*[12]float64)(monthly)
+	}
+
+	return normals, nil
+}
+
+// withAlerts wraps the weather service with the alert rules of the config, if any.
+func (s *Server) withAlerts(service weather.Service) (weather.Service, error) {
+	cfg := s.env.Cfg.Alerts
+	if len(cfg.Rules) == 0 {
+		return service, nil
+	}
 
-	rutGroup.GET("/random", rutHandler.Generate())
+	if cfg.Secret == "" {
+		return nil, errors.New("a secret is required to sign alerts")
+	}
 
-	rutGroup.Use(cache.CacheByRequestURI(store, time.Hour))
-	rutGroup.GET("/validate", rutHandler.Validate())
-	rutGroup.GET("/digit", rutHandler.VD())
-	rutGroup.GET("/activities", rutHandler.Activity())
+	rules := make([]weather.Rule, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		rules[i] = weather.Rule{
+			Name:       rule.Name,
+			Station:    rule.Station,
+			Metric:     rule.Metric,
+			Comparator: rule.Comparator,
+			Threshold:  rule.Threshold,
+			Webhook:    rule.Webhook,
+		}
+	}
 
-	economyHandler := economy.NewHandler(server.env, economy.NewDefaultService())
-	economyGroup := server.engine.Group("/economy")
+	s.notifier = weather.NewNotifier(s.env, cfg.Secret, cfg.MaxAttempts, cfg.RetryBackoff)
+	return weather.NewAlertingService(service, s.notifier, rules)
+}
 
-	economyGroup.Use(cache.CacheByRequestURI(store, time.Minute*5))
-	economyGroup.GET("/indicators", economyHandler.Indicators())
-	economyGroup.GET("/currencies", economyHandler.Currencies())
+func (s *Server) upstreamOptions(name string) []upstream.Option {
+	breaker := upstream.NewBreaker(name, s.env.Cfg.Upstream.BreakerThreshold, s.env.Cfg.Upstream.BreakerCooldown)
+	s.breakers = append(s.breakers, breaker)
 
-	weatherHandler := weather.NewHandler(server.env, weather.NewDefaultService())
-	weatherGroup := server.engine.Group("/weather")
+	opts := []upstream.Option{
+		upstream.WithBreaker(breaker),
+		upstream.WithMonitor(s.monitor),
+	}
+
+	if s.tracing != nil {
+		// Applied last, so requests rejected by the breaker are traced too
+		opts = append(opts, upstream.WithTracing(s.tracing))
+	}
 
-	weatherGroup.Use(cache.CacheByRequestURI(store, time.Minute*5))
-	weatherGroup.GET("/stations", weatherHandler.Stations())
+	return opts
 }
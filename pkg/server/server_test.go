@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	server.engine.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.serve(listener)
+	}()
+
+	url := "http://" + listener.Addr().String()
+
+	type result struct {
+		body string
+		err  error
+	}
+
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, server.Shutdown(ctx))
+	assert.NoError(t, <-served)
+
+	got := <-response
+	require.NoError(t, got.err)
+	assert.Equal(t, "done", got.body)
+
+	_, err = http.Get(url + "/ping")
+	assert.Error(t, err)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server.engine.GET("/stuck", func(c *gin.Context) {
+		close(started)
+		<-release
+	})
+	defer close(release)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go server.serve(listener)
+	go http.Get("http://" + listener.Addr().String() + "/stuck")
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, server.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestExampleConfig(t *testing.T) {
+	v := viper.New()
+	v.SetConfigFile("../../config.toml.example")
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadInConfig())
+
+	// Starts without Redis, a collector, keys or webhooks to reach
+	server, err := NewServer(config.FromViperInstance(v))
+	require.NoError(t, err)
+	assert.Nil(t, server.redis)
+	assert.Nil(t, server.tracing)
+	assert.Nil(t, server.notifier)
+	assert.Empty(t, server.env.Cfg.APIKeys.Keys)
+	assert.Empty(t, server.env.Cfg.Weather.HistoryPath)
+}
+
+func TestInvalidHistoryInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		_, err := NewServer(func(cfg *config.Config) *config.Config {
+			cfg.Weather.HistoryInterval = interval
+			return cfg
+		})
+		assert.Error(t, err)
+	}
+}
+
+func TestInvalidBreakerThreshold(t *testing.T) {
+	for _, threshold := range []int{0, -1} {
+		_, err := NewServer(func(cfg *config.Config) *config.Config {
+			cfg.Upstream.BreakerThreshold = threshold
+			return cfg
+		})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/gin-gonic/gin"
+)
+
+func statusHandler(server *Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var breakers []upstream.BreakerStatus
+		for _, breaker := range server.breakers {
+			breakers = append(breakers, breaker.Status())
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data": gin.H{
+				"breakers": breakers,
+				"sources":  server.monitor.Status(),
+			},
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	require.NotEmpty(t, server.breakers)
+
+	breaker := server.breakers[0]
+	for i := 0; i < server.env.Cfg.Upstream.BreakerThreshold; i++ {
+		breaker.Record(false)
+	}
+
+	fetchErr := errors.New("connection refused")
+	server.monitor.Start(breaker.Name()).Done(&fetchErr)
+
+	w := get(server, "/status")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Status string `json:"status"`
+		Data   struct {
+			Breakers []upstream.BreakerStatus `json:"breakers"`
+			Sources  []upstream.SourceStatus  `json:"sources"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+	assert.Equal(t, "success", body.Status)
+	assert.Len(t, body.Data.Breakers, len(server.breakers))
+	assert.Contains(t, body.Data.Breakers, upstream.BreakerStatus{
+		Name:                breaker.Name(),
+		State:               "open",
+		ConsecutiveFailures: server.env.Cfg.Upstream.BreakerThreshold,
+		RetryAfter:          int(server.env.Cfg.Upstream.BreakerCooldown.Seconds()),
+	})
+
+	var source *upstream.SourceStatus
+	for i := range body.Data.Sources {
+		if body.Data.Sources[i].Name == breaker.Name() {
+			source = &body.Data.Sources[i]
+		}
+	}
+
+	require.NotNil(t, source)
+	assert.Equal(t, 1, source.Fetches)
+	assert.Equal(t, 1, source.Failures)
+	assert.Equal(t, "connection refused", source.LastError)
+	assert.NotNil(t, source.LastErrorAt)
+	assert.Nil(t, source.LastSuccess)
+	assert.Nil(t, source.ParseOK)
+}
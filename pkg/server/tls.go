@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/pkg/errors"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// certificateLoader serves the certificate of the key pair files, reloading it when they change so renewed
+// certificates are picked up without a restart.
+type certificateLoader struct {
+	env      *env.Env
+	certFile string
+	keyFile  string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertificateLoader(env *env.Env, certFile, keyFile string) (*certificateLoader, error) {
+	loader := &certificateLoader{
+		env:      env,
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	_, err := loader.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return loader, nil
+}
+
+func (l *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.certificate, nil
+}
+
+// reload loads the key pair if either file changed since the last load. On error the current certificate is kept.
+func (l *certificateLoader) reload() (bool, error) {
+	certInfo, err := os.Stat(l.certFile)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to stat certificate")
+	}
+
+	keyInfo, err := os.Stat(l.keyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to stat key")
+	}
+
+	l.mu.RLock()
+	unchanged := l.certificate != nil && certInfo.ModTime().Equal(l.certModTime) && keyInfo.ModTime().Equal(l.keyModTime)
+	l.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to load key pair")
+	}
+
+	l.mu.Lock()
+	l.certificate = &certificate
+	l.certModTime = certInfo.ModTime()
+	l.keyModTime = keyInfo.ModTime()
+	l.mu.Unlock()
+
+	return true, nil
+}
+
+// watch checks the files for changes every interval until the context is done.
+func (l *certificateLoader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := l.reload()
+		if err != nil {
+			l.env.Logger.Errorf("unable to reload TLS certificate, keeping the current one: %v", err)
+			continue
+		}
+
+		if reloaded {
+			l.env.Logger.Infof("reloaded TLS certificate %s", l.certFile)
+		}
+	}
+}
+
+func newTLSConfig(cfg config.TLS, loader *certificateLoader) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q, use 1.2 or 1.3", cfg.MinVersion)
+	}
+
+	ciphers, err := cipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   ciphers,
+		GetCertificate: loader.getCertificate,
+	}, nil
+}
+
+// cipherSuites resolves the names of the TLS 1.2 cipher suites. Go's secure defaults are used when none are given,
+// and TLS 1.3 suites aren't configurable.
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				available[suite.Name] = suite.ID
+			}
+		}
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS 1.2 cipher suite %q", name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// setupTLS configures the server to serve HTTPS if a certificate is set, along with the redirect from HTTP.
+func setupTLS(server *Server) error {
+	cfg := server.env.Cfg.HTTP
+	if cfg.TLS.CertFile == "" && cfg.TLS.KeyFile == "" {
+		return nil
+	}
+
+	if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+		return errors.New("both a certificate and a key file are required")
+	}
+
+	loader, err := newCertificateLoader(server.env, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	server.httpServer.TLSConfig, err = newTLSConfig(cfg.TLS, loader)
+	if err != nil {
+		return err
+	}
+
+	server.certificates = loader
+
+	if cfg.TLS.RedirectPort != "" {
+		server.redirectServer = &http.Server{
+			Addr:              net.JoinHostPort(cfg.Address, cfg.TLS.RedirectPort),
+			Handler:           redirectHandler(cfg.Port),
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+	}
+
+	return nil
+}
+
+// redirectHandler redirects every request to the same URL over HTTPS on the given port.
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
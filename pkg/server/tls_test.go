@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate for localhost with the given serial number.
+func writeCertificate(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func withTLS(tlsCfg config.TLS) config.Option {
+	return func(cfg *config.Config) *config.Config {
+		cfg.HTTP.TLS = tlsCfg
+		return cfg
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert := writeCertificate(t, certFile, keyFile, 1)
+
+	server, err := NewServer(withTLS(config.TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"}))
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go server.serve(listener)
+	defer server.Shutdown(context.Background())
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + listener.Addr().String() + "/ping")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "pong", string(body))
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+
+	// TLS 1.2 clients are refused
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    pool,
+		MaxVersion: tls.VersionTLS12,
+	}}}
+
+	_, err = client.Get("https://" + listener.Addr().String() + "/ping")
+	assert.Error(t, err)
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, 1)
+
+	loader, err := newCertificateLoader(newTestEnv(), certFile, keyFile)
+	require.NoError(t, err)
+
+	reloaded, err := loader.reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeCertificate(t, certFile, keyFile, 2)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	reloaded, err = loader.reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	current, err := loader.getCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(current.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), leaf.SerialNumber.Int64())
+
+	// A broken pair keeps the current certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0600))
+	evenLater := later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, evenLater, evenLater))
+
+	_, err = loader.reload()
+	assert.Error(t, err)
+
+	kept, err := loader.getCertificate(nil)
+	require.NoError(t, err)
+	assert.Same(t, current, kept)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.TLS
+		want    []uint16
+		wantErr bool
+	}{
+		{"defaults", config.TLS{MinVersion: "1.2"}, nil, false},
+		{"ciphers", config.TLS{MinVersion: "1.2", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			[]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, false},
+		{"old version", config.TLS{MinVersion: "1.0"}, nil, true},
+		{"insecure cipher", config.TLS{MinVersion: "1.2", CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, nil, true},
+		{"TLS 1.3 cipher", config.TLS{MinVersion: "1.2", CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTLSConfig(tt.cfg, &certificateLoader{})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.CipherSuites)
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port   string
+		host   string
+		target string
+		want   string
+	}{
+		{"443", "libreapi.cl", "/v1/rut/digit?rut=1", "https://libreapi.cl/v1/rut/digit?rut=1"},
+		{"443", "libreapi.cl:80", "/ping", "https://libreapi.cl/ping"},
+		{"8443", "localhost:8080", "/ping", "https://localhost:8443/ping"},
+		{"443", "[::1]:80", "/ping", "https://[::1]/ping"},
+		{"8443", "[::1]", "/ping", "https://[::1]:8443/ping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+
+			w := httptest.NewRecorder()
+			redirectHandler(tt.port).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestSetupTLSRequiresPair(t *testing.T) {
+	_, err := NewServer(withTLS(config.TLS{CertFile: "cert.pem", MinVersion: "1.2"}))
+	assert.Error(t, err)
+}
+
+func newTestEnv() *env.Env {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return &env.Env{Logger: logger, Cfg: config.Default()}
+}
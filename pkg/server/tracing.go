@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+
+	"github.com/ccuetoh/libreapi/pkg"
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newSpanExporter sends the traces to the OTLP/HTTP collector of cfg. It doesn't connect until the first export.
+func newSpanExporter(cfg config.Tracing) (sdktrace.SpanExporter, error) {
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.Endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	}
+
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+func newTracerProvider(cfg config.Tracing, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+		semconv.ServiceVersionKey.String(libreapi.Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	), nil
+}
+
+// redactSpanMiddleware replaces the target recorded by otelgin, so API keys passed in the query aren't exported.
+func redactSpanMiddleware(queryParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if queryParam == "" || c.Request.URL.Query().Get(queryParam) == "" {
+			return
+		}
+
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(semconv.HTTPTargetKey.String(redactedURI(c, queryParam)))
+	}
+}
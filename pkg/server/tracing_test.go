@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	server, err := newServer(config.Build(), exporter)
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	server.env.Logger.SetOutput(&logs)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/rut/digit?rut=11111111", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	server.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.NoError(t, server.tracing.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "/v1/rut/digit", span.Name)
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Contains(t, span.Attributes, attribute.Int("http.status_code", http.StatusOK))
+	assert.Equal(t, "libreapi", resourceAttribute(span, "service.name"))
+	assert.Contains(t, span.Attributes, attribute.String("http.request_id", w.Header().Get("X-Request-ID")))
+
+	// Logs are correlated with the trace
+	assert.Contains(t, logs.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestTracingRedactsAPIKey(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	server, err := newServer(config.Build(), exporter)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/rut/digit?rut=11111111&api_key=s3cr3t-k3y", nil)
+	server.engine.ServeHTTP(w, req)
+
+	require.NoError(t, server.tracing.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+
+	for _, attr := range spans[0].Attributes {
+		assert.NotContains(t, attr.Value.Emit(), "s3cr3t-k3y", "attribute %s", attr.Key)
+	}
+
+	assert.Contains(t, spans[0].Attributes, attribute.String("http.target", "/v1/rut/digit?api_key=REDACTED&rut=11111111"))
+}
+
+func TestTracingDisabled(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	assert.Nil(t, server.tracing)
+	assert.Nil(t, server.env.TracerProvider)
+
+	server, err = NewServer(func(cfg *config.Config) *config.Config {
+		cfg.Tracing.Endpoint = "localhost:4318"
+		return cfg
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, server.tracing)
+}
+
+func resourceAttribute(span tracetest.SpanStub, key attribute.Key) string {
+	value, _ := span.Resource.Set().Value(key)
+	return value.AsString()
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+	"github.com/ccuetoh/libreapi/pkg/economy"
+	"github.com/ccuetoh/libreapi/pkg/rut"
+	"github.com/ccuetoh/libreapi/pkg/weather"
+
+	"github.com/chenyahui/gin-cache/persist"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// apiVersion is a version of the API served under its name, such as /v1. The handlers are built once and mounted on
+// every group the version is served at, so the upstream services and their breakers aren't duplicated.
+type apiVersion struct {
+	name  string
+	build func(server *Server) (func(group *gin.RouterGroup), error)
+}
+
+// apiVersions are served side by side. A breaking change to a response goes into a new version, appended here along
+// with its operations in the OpenAPI document, while the previous ones keep their behavior.
+var apiVersions = []apiVersion{
+	{name: "v1", build: v1Routes},
+}
+
+// unversioned is the version also served without a prefix, as deprecated aliases kept for a transition period.
+const unversioned = "v1"
+
+func v1Routes(server *Server) (func(group *gin.RouterGroup), error) {
+	store := persist.NewMemoryStore(time.Minute)
+	keep := keepHeaders(perRequestHeaders(server.env.Cfg))
+	maxStaleness := server.env.Cfg.Upstream.MaxStaleness
+
+	rutService := rut.NewDefaultService(server.upstreamOptions("sii")...)
+	rutHandler := rut.NewHandler(server.env, rutService)
+
+	economyService := economy.NewValidatingService(economy.NewDefaultService(server.upstreamOptions("bcentral")...))
+	economyHandler := economy.NewHandler(server.env, economy.NewStaleService(economyService, maxStaleness))
+
+	var weatherService weather.Service = weather.NewDefaultService(server.upstreamOptions("meteochile")...)
+
+	weatherService, err := server.withAlerts(weatherService)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to set up weather alerts")
+	}
+
+	server.collector = weather.NewCollector(server.env, weatherService, server.history)
+	weatherHandler := weather.NewHandler(server.env, weather.NewStaleService(server.collector, maxStaleness))
+
+	normals, err := precipitationNormals(server.env.Cfg.Weather.PrecipitationNormals)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid precipitation normals")
+	}
+
+	return func(group *gin.RouterGroup) {
+		group.GET(usagePath, usageHandler(server.env))
+
+		rutGroup := group.Group("/rut")
+
+		rutGroup.GET("/random", rutHandler.Generate())
+
+		rutGroup.Use(server.metrics.cache("rut", store, time.Hour, keep))
+		rutGroup.GET("/validate", rutHandler.Validate())
+		rutGroup.GET("/digit", rutHandler.VD())
+		rutGroup.GET("/activities", rutHandler.Activity())
+
+		economyGroup := group.Group("/economy")
+
+		economyGroup.Use(server.metrics.cache("economy", store, time.Minute*5, keep), skipStale())
+		economyGroup.GET("/indicators", economyHandler.Indicators())
+		economyGroup.GET("/currencies", economyHandler.Currencies())
+
+		weatherGroup := group.Group("/weather")
+
+		weatherGroup.Use(server.metrics.cache("weather", store, time.Minute*5, keep), skipStale())
+		weatherGroup.GET("/stations", weatherHandler.Stations())
+		weatherGroup.GET("/stations/nearest", weatherHandler.Nearest())
+		weatherGroup.GET("/summary", weatherHandler.Summary())
+		weatherGroup.GET("/stations/:code/history", weatherHandler.History(server.collector))
+		weatherGroup.GET("/stations/:code/precipitation", weatherHandler.Precipitation(server.collector, normals))
+	}, nil
+}
+
+// deprecation holds the dates announced on the unversioned aliases.
+type deprecation struct {
+	since  time.Time
+	sunset time.Time
+}
+
+func newDeprecation(cfg config.API) (*deprecation, error) {
+	since, err := time.Parse(time.RFC3339, cfg.UnversionedDeprecation)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid unversioned deprecation date")
+	}
+
+	sunset, err := time.Parse(time.RFC3339, cfg.UnversionedSunset)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid unversioned sunset date")
+	}
+
+	if !sunset.After(since) {
+		return nil, fmt.Errorf("sunset %v isn't after the deprecation %v", sunset, since)
+	}
+
+	return &deprecation{since: since, sunset: sunset}, nil
+}
+
+// middleware adds the Deprecation (RFC 9745) and Sunset (RFC 8594) headers, along with a link to the same route in
+// the given version.
+func (d *deprecation) middleware(successor string) gin.HandlerFunc {
+	deprecationHeader := "@" + strconv.FormatInt(d.since.Unix(), 10)
+	sunsetHeader := d.sunset.UTC().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecationHeader)
+		c.Header("Sunset", sunsetHeader)
+		c.Header("Link", fmt.Sprintf(`</%s%s>; rel="successor-version"`, successor, c.Request.URL.EscapedPath()))
+	}
+}
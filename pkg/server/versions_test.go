@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnversionedAliases(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/rut/digit?rut=11111111", nil)
+	server.engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+	versioned := w.Body.String()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/rut/digit?rut=11111111", nil)
+	server.engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, versioned, w.Body.String())
+	assert.Equal(t, "@1792368000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/rut/digit>; rel="successor-version"`, w.Header().Get("Link"))
+}
+
+func TestNewDeprecation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.API
+		wantErr bool
+	}{
+		{"valid", config.API{UnversionedDeprecation: "2026-10-19T00:00:00Z", UnversionedSunset: "2027-04-19T00:00:00Z"}, false},
+		{"invalid deprecation", config.API{UnversionedDeprecation: "2026-10-19", UnversionedSunset: "2027-04-19T00:00:00Z"}, true},
+		{"invalid sunset", config.API{UnversionedDeprecation: "2026-10-19T00:00:00Z", UnversionedSunset: "soon"}, true},
+		{"sunset before deprecation", config.API{UnversionedDeprecation: "2027-04-19T00:00:00Z", UnversionedSunset: "2026-10-19T00:00:00Z"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newDeprecation(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), got.since)
+		})
+	}
+}
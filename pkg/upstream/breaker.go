@@ -0,0 +1,208 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// OpenError is returned instead of executing a request while the breaker of the source is open.
+type OpenError struct {
+	Source     string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for '%s' is open, retry in %s", e.Source, e.RetryAfter.Round(time.Second))
+}
+
+// RetryAfter reports whether err was caused by an open breaker, and the amount of seconds until it's worth retrying.
+func RetryAfter(err error) (int, bool) {
+	var openErr *OpenError
+	if !errors.As(err, &openErr) {
+		return 0, false
+	}
+
+	seconds := int(math.Ceil(openErr.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds, true
+}
+
+// Breaker is a circuit breaker for a single upstream source. It opens after threshold consecutive failures,
+// rejecting requests until cooldown has passed, and then lets a single probe through to decide whether to close again.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+type BreakerStatus struct {
+	Name                string `json:"name"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	RetryAfter          int    `json:"retry_after,omitempty"`
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.currentState()
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Name:                b.name,
+		State:               b.currentState().String(),
+		ConsecutiveFailures: b.failures,
+	}
+
+	if b.state == BreakerOpen {
+		status.RetryAfter = int(math.Ceil(b.remaining().Seconds()))
+	}
+
+	return status
+}
+
+// Allow returns an OpenError if a request to the source shouldn't be attempted right now. Every allowed request
+// must be followed by a call to Record, or to release if it was cancelled by the caller.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case BreakerOpen:
+		return &OpenError{Source: b.name, RetryAfter: b.remaining()}
+	case BreakerHalfOpen:
+		if b.probing {
+			// Only a single probe is let through while half-open
+			return &OpenError{Source: b.name, RetryAfter: b.cooldown}
+		}
+
+		b.probing = true
+	}
+
+	return nil
+}
+
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release lets another probe through without recording a result.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// RoundTripper wraps next so every request goes through the breaker. Transport errors and 5xx responses count as
+// failures.
+func (b *Breaker) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return &breakerTransport{
+		breaker: b,
+		next:    next,
+	}
+}
+
+func (b *Breaker) currentState() BreakerState {
+	if b.state == BreakerOpen && b.remaining() <= 0 {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+func (b *Breaker) remaining() time.Duration {
+	return b.openedAt.Add(b.cooldown).Sub(b.now())
+}
+
+type breakerTransport struct {
+	breaker *Breaker
+	next    http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+		// The caller gave up, which says nothing about the source
+		t.breaker.release()
+		return res, err
+	}
+
+	t.breaker.Record(err == nil && res.StatusCode < http.StatusInternalServerError)
+
+	return res, err
+}
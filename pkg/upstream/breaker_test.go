@@ -0,0 +1,107 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	breaker := NewBreaker("test", 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.NoError(t, breaker.Allow())
+	breaker.Record(false)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.NoError(t, breaker.Allow())
+	breaker.Record(false)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	err := breaker.Allow()
+	assert.Error(t, err)
+
+	retryAfter, open := RetryAfter(err)
+	assert.True(t, open)
+	assert.Equal(t, 60, retryAfter)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+
+	// A single probe is allowed while half-open
+	assert.NoError(t, breaker.Allow())
+	assert.Error(t, breaker.Allow())
+
+	breaker.Record(false)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, breaker.Allow())
+
+	breaker.Record(true)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.Equal(t, BreakerStatus{Name: "test", State: "closed"}, breaker.Status())
+}
+
+func TestBreakerClient(t *testing.T) {
+	calls := 0
+
+	badGateway := func(client *Client) {
+		client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+
+			recorder := httptest.NewRecorder()
+			recorder.WriteHeader(http.StatusBadGateway)
+
+			return recorder.Result(), nil
+		})
+	}
+
+	breaker := NewBreaker("test", 1, time.Minute)
+	client := NewClient(time.Second, badGateway, WithBreaker(breaker))
+
+	res, err := client.Get("http://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+
+	_, err = client.Get("http://example.com")
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, open := RetryAfter(errors.Wrap(err, "unable to execute request"))
+	assert.True(t, open)
+}
+
+func TestBreakerClientCancelled(t *testing.T) {
+	cancelled := func(client *Client) {
+		client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, req.Context().Err()
+		})
+	}
+
+	breaker := NewBreaker("test", 1, time.Minute)
+	client := NewClient(time.Second, cancelled, WithBreaker(breaker))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.GetContext(ctx, "http://example.com")
+	assert.Error(t, err)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.Equal(t, 0, breaker.Status().ConsecutiveFailures)
+}
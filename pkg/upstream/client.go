@@ -0,0 +1,85 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Client is the http.Client used by a service to reach its upstream sources, along with the tooling that observes it.
+type Client struct {
+	*http.Client
+	monitor *Monitor
+}
+
+type Option func(client *Client)
+
+// WithBreaker routes every request of the client through breaker.
+func WithBreaker(breaker *Breaker) Option {
+	return func(client *Client) {
+		client.Transport = breaker.RoundTripper(client.transport())
+	}
+}
+
+// WithMonitor reports the fetches made by the client to monitor.
+func WithMonitor(monitor *Monitor) Option {
+	return func(client *Client) {
+		client.monitor = monitor
+	}
+}
+
+// WithTracing creates a span for every request of the client, as a child of the span in the context of the request.
+func WithTracing(provider trace.TracerProvider) Option {
+	return func(client *Client) {
+		client.Transport = otelhttp.NewTransport(client.transport(),
+			otelhttp.WithTracerProvider(provider),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + r.URL.Host + r.URL.Path
+			}))
+	}
+}
+
+func NewClient(timeout time.Duration, opts ...Option) *Client {
+	client := &Client{
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+
+	for _, op := range opts {
+		op(client)
+	}
+
+	return client
+}
+
+// Register declares the sources reached by the client, so they are reported before their first fetch.
+func (c *Client) Register(sources ...string) {
+	c.monitor.Register(sources...)
+}
+
+// Track starts tracking a fetch from source. It's safe to use when the client has no monitor.
+func (c *Client) Track(source string) *Fetch {
+	return c.monitor.Start(source)
+}
+
+// GetContext issues a GET to url bound to ctx, so it's cancelled and traced along with the request that caused it.
+func (c *Client) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *Client) transport() http.RoundTripper {
+	if c.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return c.Transport
+}
@@ -0,0 +1,44 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClientTracing(t *testing.T) {
+	ok := func(client *Client) {
+		client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.WriteHeader(http.StatusOK)
+
+			return recorder.Result(), nil
+		})
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	client := NewClient(time.Second, ok, WithTracing(provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	res, err := client.GetContext(ctx, "https://zeus.sii.cl/cvc_cgi/stc/CViewCaptcha.cgi?oper=0")
+	require.NoError(t, err)
+	res.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	fetch := spans[0]
+	assert.Equal(t, "GET zeus.sii.cl/cvc_cgi/stc/CViewCaptcha.cgi", fetch.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), fetch.Parent.SpanID())
+	assert.Equal(t, parent.SpanContext().TraceID(), fetch.SpanContext.TraceID())
+}
@@ -0,0 +1,78 @@
+package upstream
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrImplausible signals that the parsed data is outside any realistic value, usually because the upstream moved its
+// labels around.
+var ErrImplausible = errors.New("implausible data")
+
+// Failure describes how a failed fetch is reported to the client and logged.
+type Failure struct {
+	Status  int
+	Message string
+	// RetryAfter is the amount of seconds sent in the Retry-After header, which is omitted when zero
+	RetryAfter int
+	Level      logrus.Level
+	Log        string
+}
+
+// Classify maps the error of a failed fetch to its Failure. Unknown errors are reported with the fallback message.
+func Classify(err error, fallback string) Failure {
+	if retryAfter, open := RetryAfter(err); open {
+		return Failure{
+			Status:     http.StatusServiceUnavailable,
+			Message:    "upstream source unavailable",
+			RetryAfter: retryAfter,
+			Level:      logrus.WarnLevel,
+			Log:        "upstream unavailable",
+		}
+	}
+
+	if IsLayoutChanged(err) {
+		return Failure{
+			Status:  http.StatusBadGateway,
+			Message: "upstream layout changed",
+			Level:   logrus.ErrorLevel,
+			Log:     "upstream layout changed",
+		}
+	}
+
+	if errors.Is(err, ErrImplausible) {
+		return Failure{
+			Status:  http.StatusBadGateway,
+			Message: "upstream returned implausible data",
+			Level:   logrus.ErrorLevel,
+			Log:     "implausible data",
+		}
+	}
+
+	return Failure{
+		Status:  http.StatusInternalServerError,
+		Message: fallback,
+		Level:   logrus.ErrorLevel,
+		Log:     "unable to fetch data",
+	}
+}
+
+// RespondFailure replies to a failed fetch and logs it. The body is built by each package from the message, so
+// every API keeps its own error shape.
+func RespondFailure(c *gin.Context, log *logrus.Entry, err error, fallback string, body func(message string) gin.H) {
+	// Logged along the request
+	_ = c.Error(err)
+
+	failure := Classify(err, fallback)
+	if failure.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(failure.RetryAfter))
+	}
+
+	c.JSON(failure.Status, body(failure.Message))
+
+	log.WithError(err).Log(failure.Level, failure.Log)
+}
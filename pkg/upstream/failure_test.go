@@ -0,0 +1,31 @@
+package upstream
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	open := errors.Wrap(&OpenError{Source: "source", RetryAfter: 1500 * time.Millisecond}, "unable to execute request")
+	failure := Classify(open, "unable to fetch data")
+	assert.Equal(t, http.StatusServiceUnavailable, failure.Status)
+	assert.Equal(t, 2, failure.RetryAfter)
+
+	layout := errors.Wrap(&LayoutError{Source: "source", Mismatches: []string{"missing table"}}, "unable to parse html")
+	failure = Classify(layout, "unable to fetch data")
+	assert.Equal(t, http.StatusBadGateway, failure.Status)
+	assert.Equal(t, "upstream layout changed", failure.Message)
+	assert.Zero(t, failure.RetryAfter)
+
+	failure = Classify(errors.Wrap(ErrImplausible, "unable to validate"), "unable to fetch data")
+	assert.Equal(t, http.StatusBadGateway, failure.Status)
+	assert.Equal(t, "upstream returned implausible data", failure.Message)
+
+	failure = Classify(errors.New("connection refused"), "unable to get data")
+	assert.Equal(t, http.StatusInternalServerError, failure.Status)
+	assert.Equal(t, "unable to get data", failure.Message)
+}
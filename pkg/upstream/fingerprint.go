@@ -0,0 +1,83 @@
+package upstream
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+)
+
+// ErrLayoutChanged signals that a page no longer has the structure its parser expects.
+var ErrLayoutChanged = errors.New("upstream layout changed")
+
+// LayoutError lists the expectations of a Fingerprint a page failed to meet.
+type LayoutError struct {
+	Source     string
+	Mismatches []string
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("%v for '%s': %s", ErrLayoutChanged, e.Source, strings.Join(e.Mismatches, "; "))
+}
+
+func (e *LayoutError) Is(target error) bool {
+	return target == ErrLayoutChanged
+}
+
+// IsLayoutChanged reports whether err was caused by a page failing its Fingerprint.
+func IsLayoutChanged(err error) bool {
+	return errors.Is(err, ErrLayoutChanged)
+}
+
+// Expectation is a single structural feature a page must have.
+type Expectation struct {
+	// Selector must match at least Min elements, or at least one if Min is zero
+	Selector string
+	Min      int
+	// Text, if set, must be contained in the text of the first match. The comparison ignores case
+	Text string
+}
+
+// Fingerprint describes the structure a parser relies on, so changes in the upstream layout are detected before
+// they turn into zeros or confusing parse errors.
+type Fingerprint struct {
+	Source       string
+	Expectations []Expectation
+}
+
+func (f Fingerprint) Validate(doc *goquery.Document) error {
+	var mismatches []string
+	for _, expect := range f.Expectations {
+		min := expect.Min
+		if min == 0 {
+			min = 1
+		}
+
+		matches := doc.Find(expect.Selector)
+		if matches.Length() < min {
+			mismatches = append(mismatches,
+				fmt.Sprintf("expected at least %d '%s', got %d", min, expect.Selector, matches.Length()))
+			continue
+		}
+
+		if expect.Text == "" {
+			continue
+		}
+
+		text := strings.TrimSpace(matches.First().Text())
+		if !strings.Contains(strings.ToLower(text), strings.ToLower(expect.Text)) {
+			mismatches = append(mismatches,
+				fmt.Sprintf("expected '%s' to contain '%s', got '%s'", expect.Selector, expect.Text, text))
+		}
+	}
+
+	if len(mismatches) != 0 {
+		return &LayoutError{
+			Source:     f.Source,
+			Mismatches: mismatches,
+		}
+	}
+
+	return nil
+}
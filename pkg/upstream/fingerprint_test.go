@@ -0,0 +1,52 @@
+package upstream
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	page := `<html><body>
+		<label id="lblSerie1_3">Dólar observado</label><label id="lblValor1_3">945,31</label>
+		<table><tr><td>1</td></tr><tr><td>2</td></tr></table>
+	</body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("unable to create document: %v", err)
+	}
+
+	ok := Fingerprint{
+		Source: "test",
+		Expectations: []Expectation{
+			{Selector: "label#lblSerie1_3", Text: "dólar"},
+			{Selector: "label#lblValor1_3"},
+			{Selector: "tr", Min: 2},
+		},
+	}
+	assert.NoError(t, ok.Validate(doc))
+
+	changed := Fingerprint{
+		Source: "test",
+		Expectations: []Expectation{
+			{Selector: "label#lblSerie1_3", Text: "Euro"},
+			{Selector: "label#lblValor1_5"},
+			{Selector: "tr", Min: 3},
+		},
+	}
+
+	err = changed.Validate(doc)
+	assert.Error(t, err)
+	assert.True(t, IsLayoutChanged(err))
+	assert.True(t, IsLayoutChanged(errors.Wrap(err, "unable to parse html")))
+
+	var layoutErr *LayoutError
+	assert.True(t, errors.As(err, &layoutErr))
+	assert.Len(t, layoutErr.Mismatches, 3)
+
+	assert.False(t, IsLayoutChanged(errors.New("server is on fire")))
+}
@@ -0,0 +1,255 @@
+package upstream
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// latencyWindow is the amount of recent fetches the latency percentiles are computed over.
+const latencyWindow = 100
+
+// Monitor keeps track of the health of every upstream source.
+type Monitor struct {
+	mu        sync.Mutex
+	sources   map[string]*source
+	order     []string
+	observers []FetchObserver
+	now       func() time.Time
+}
+
+// FetchObserver is called with the outcome of every fetch, such as to export it as metrics.
+type FetchObserver func(source string, latency time.Duration, err error)
+
+type source struct {
+	name          string
+	fetches       int
+	failures      int
+	layoutChanges int
+	lastSuccess   time.Time
+	lastError     string
+	lastErrorAt   time.Time
+	parseOK       *bool
+	latencies     []time.Duration
+	next          int
+}
+
+type SourceStatus struct {
+	Name          string     `json:"name"`
+	Fetches       int        `json:"fetches"`
+	Failures      int        `json:"failures"`
+	LayoutChanges int        `json:"layout_changes"`
+	LastSuccess   *time.Time `json:"last_success"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	ParseOK       *bool      `json:"parse_ok"`
+	Latency       Latency    `json:"latency_ms"`
+}
+
+type Latency struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Fetch tracks a single fetch from a source. A nil *Fetch is valid and records nothing.
+type Fetch struct {
+	monitor *Monitor
+	source  string
+	start   time.Time
+	latency time.Duration
+	fetched bool
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{
+		sources: make(map[string]*source),
+		now:     time.Now,
+	}
+}
+
+func (m *Monitor) Register(names ...string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, name := range names {
+		m.get(name)
+	}
+}
+
+// Observe adds an observer of the fetches. It must be called before fetching.
+func (m *Monitor) Observe(observer FetchObserver) {
+	if m == nil {
+		return
+	}
+
+	m.observers = append(m.observers, observer)
+}
+
+func (m *Monitor) Start(name string) *Fetch {
+	if m == nil {
+		return nil
+	}
+
+	return &Fetch{
+		monitor: m,
+		source:  name,
+		start:   m.now(),
+	}
+}
+
+func (m *Monitor) Status() []SourceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var statuses []SourceStatus
+	for _, name := range m.order {
+		statuses = append(statuses, m.sources[name].status())
+	}
+
+	return statuses
+}
+
+// Fetched marks the response of the source as received. Errors reported after this point are considered parsing
+// errors.
+func (f *Fetch) Fetched() {
+	if f == nil {
+		return
+	}
+
+	f.latency = f.monitor.now().Sub(f.start)
+	f.fetched = true
+}
+
+// Done records the outcome of the fetch. It takes a pointer so it can be deferred with a named error result. Fetches
+// that aren't Measurable are only passed to the observers.
+func (f *Fetch) Done(err *error) {
+	if f == nil {
+		return
+	}
+
+	m := f.monitor
+
+	now := m.now()
+	if !f.fetched {
+		f.latency = now.Sub(f.start)
+	}
+
+	for _, observer := range m.observers {
+		observer(f.source, f.latency, *err)
+	}
+
+	if !Measurable(*err) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.get(f.source)
+	s.fetches++
+	s.addLatency(f.latency)
+
+	if *err == nil {
+		parsed := true
+		s.lastSuccess = now
+		s.parseOK = &parsed
+		return
+	}
+
+	s.failures++
+	s.lastError = (*err).Error()
+	s.lastErrorAt = now
+
+	if f.fetched {
+		parsed := false
+		s.parseOK = &parsed
+	}
+
+	if IsLayoutChanged(*err) {
+		s.layoutChanges++
+	}
+}
+
+// Measurable reports whether a fetch ending with err tells anything about the health of the source. Fetches refused by
+// an open breaker or abandoned by the caller don't, and their latency is meaningless.
+func Measurable(err error) bool {
+	var openErr *OpenError
+	return !errors.As(err, &openErr) && !errors.Is(err, context.Canceled)
+}
+
+func (m *Monitor) get(name string) *source {
+	s, exists := m.sources[name]
+	if !exists {
+		s = &source{name: name}
+		m.sources[name] = s
+		m.order = append(m.order, name)
+	}
+
+	return s
+}
+
+func (s *source) addLatency(latency time.Duration) {
+	if len(s.latencies) < latencyWindow {
+		s.latencies = append(s.latencies, latency)
+		return
+	}
+
+	s.latencies[s.next] = latency
+	s.next = (s.next + 1) % latencyWindow
+}
+
+func (s *source) status() SourceStatus {
+	status := SourceStatus{
+		Name:          s.name,
+		Fetches:       s.fetches,
+		Failures:      s.failures,
+		LayoutChanges: s.layoutChanges,
+		LastError:     s.lastError,
+		ParseOK:       s.parseOK,
+	}
+
+	if !s.lastSuccess.IsZero() {
+		lastSuccess := s.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+
+	if !s.lastErrorAt.IsZero() {
+		lastErrorAt := s.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+
+	if len(s.latencies) == 0 {
+		return status
+	}
+
+	sorted := make([]time.Duration, len(s.latencies))
+	copy(sorted, s.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	status.Latency = Latency{
+		P50: percentile(sorted, 50),
+		P90: percentile(sorted, 90),
+		P99: percentile(sorted, 99),
+	}
+
+	return status
+}
+
+// percentile uses the nearest-rank method over an already sorted slice, in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return float64(sorted[rank]) / float64(time.Millisecond)
+}
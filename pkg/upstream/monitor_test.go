@@ -0,0 +1,154 @@
+package upstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	monitor := NewMonitor()
+	monitor.now = func() time.Time { return now }
+	monitor.Register("first", "second")
+
+	var err error
+	for i := 1; i <= 10; i++ {
+		fetch := monitor.Start("first")
+		now = now.Add(time.Duration(i) * time.Millisecond)
+		fetch.Fetched()
+		fetch.Done(&err)
+	}
+
+	fetch := monitor.Start("first")
+	fetch.Fetched()
+	err = errors.New("unexpected layout")
+	fetch.Done(&err)
+
+	statuses := monitor.Status()
+	assert.Len(t, statuses, 2)
+
+	first := statuses[0]
+	assert.Equal(t, "first", first.Name)
+	assert.Equal(t, 11, first.Fetches)
+	assert.Equal(t, 1, first.Failures)
+	assert.Equal(t, "unexpected layout", first.LastError)
+	assert.NotNil(t, first.LastSuccess)
+	assert.NotNil(t, first.ParseOK)
+	assert.False(t, *first.ParseOK)
+	assert.Equal(t, Latency{P50: 5, P90: 9, P99: 10}, first.Latency)
+
+	second := statuses[1]
+	assert.Equal(t, "second", second.Name)
+	assert.Equal(t, 0, second.Fetches)
+	assert.Nil(t, second.LastSuccess)
+	assert.Nil(t, second.ParseOK)
+}
+
+func TestMonitorFetchError(t *testing.T) {
+	monitor := NewMonitor()
+
+	err := errors.New("connection refused")
+	monitor.Start("source").Done(&err)
+
+	status := monitor.Status()[0]
+	assert.Equal(t, 1, status.Failures)
+	assert.Nil(t, status.ParseOK)
+	assert.Nil(t, status.LastSuccess)
+}
+
+func TestMonitorLayoutChanged(t *testing.T) {
+	monitor := NewMonitor()
+
+	fetch := monitor.Start("source")
+	fetch.Fetched()
+
+	var err error = &LayoutError{Source: "source", Mismatches: []string{"missing table"}}
+	fetch.Done(&err)
+
+	status := monitor.Status()[0]
+	assert.Equal(t, 1, status.LayoutChanges)
+	assert.False(t, *status.ParseOK)
+}
+
+func TestMonitorNotMeasurable(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	monitor := NewMonitor()
+	monitor.now = func() time.Time { return now }
+
+	var observed []error
+	monitor.Observe(func(source string, latency time.Duration, err error) {
+		observed = append(observed, err)
+	})
+
+	var err error
+	fetch := monitor.Start("source")
+	now = now.Add(40 * time.Millisecond)
+	fetch.Done(&err)
+
+	var open error = &OpenError{Source: "source", RetryAfter: time.Minute}
+	monitor.Start("source").Done(&open)
+
+	canceled := errors.Wrap(context.Canceled, "unable to fetch")
+	fetch = monitor.Start("source")
+	now = now.Add(time.Millisecond)
+	fetch.Done(&canceled)
+
+	status := monitor.Status()[0]
+	assert.Equal(t, 1, status.Fetches)
+	assert.Equal(t, 0, status.Failures)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, Latency{P50: 40, P90: 40, P99: 40}, status.Latency)
+
+	assert.Equal(t, []error{nil, open, canceled}, observed)
+}
+
+func TestNilMonitor(t *testing.T) {
+	client := NewClient(time.Second)
+	client.Register("source")
+
+	var err error
+	fetch := client.Track("source")
+	fetch.Fetched()
+	fetch.Done(&err)
+
+	assert.Nil(t, fetch)
+}
+
+func TestMonitorObserve(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	monitor := NewMonitor()
+	monitor.now = func() time.Time { return now }
+
+	type observation struct {
+		source  string
+		latency time.Duration
+		err     error
+	}
+
+	var observed []observation
+	monitor.Observe(func(source string, latency time.Duration, err error) {
+		observed = append(observed, observation{source, latency, err})
+	})
+
+	var err error
+	fetch := monitor.Start("source")
+	now = now.Add(20 * time.Millisecond)
+	fetch.Done(&err)
+
+	failure := errors.New("connection refused")
+	fetch = monitor.Start("source")
+	now = now.Add(5 * time.Millisecond)
+	fetch.Done(&failure)
+
+	assert.Equal(t, []observation{
+		{"source", 20 * time.Millisecond, nil},
+		{"source", 5 * time.Millisecond, failure},
+	}, observed)
+}
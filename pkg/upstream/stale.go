@@ -0,0 +1,90 @@
+package upstream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// StaleError is returned alongside the last known good value when fetching fresh data failed, but the previous
+// result is still young enough to be served.
+type StaleError struct {
+	Age time.Duration
+	Err error
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("stale data (%s old): %v", e.Age.Round(time.Second), e.Err)
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// Staleness reports whether err signals that the returned data is stale, and how old it is.
+func Staleness(err error) (time.Duration, bool) {
+	var staleErr *StaleError
+	if errors.As(err, &staleErr) {
+		return staleErr.Age, true
+	}
+
+	return 0, false
+}
+
+// AnnotateStaleness adds the stale flag and the age of the data to a response body when err reports it.
+func AnnotateStaleness(body gin.H, err error) gin.H {
+	age, stale := Staleness(err)
+	if !stale {
+		return body
+	}
+
+	body["stale"] = true
+	body["age_seconds"] = int(age.Seconds())
+
+	return body
+}
+
+// LastKnownGood remembers the last successful result of a fetch and serves it when a later fetch fails, as long
+// as it isn't older than maxAge.
+type LastKnownGood[T any] struct {
+	mu      sync.RWMutex
+	value   T
+	fetched time.Time
+	valid   bool
+	maxAge  time.Duration
+	now     func() time.Time
+}
+
+func NewLastKnownGood[T any](maxAge time.Duration) *LastKnownGood[T] {
+	return &LastKnownGood[T]{
+		maxAge: maxAge,
+		now:    time.Now,
+	}
+}
+
+func (l *LastKnownGood[T]) Fetch(fetch func() (T, error)) (T, error) {
+	value, err := fetch()
+	if err == nil {
+		l.mu.Lock()
+		l.value = value
+		l.fetched = l.now()
+		l.valid = true
+		l.mu.Unlock()
+
+		return value, nil
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	age := l.now().Sub(l.fetched)
+	if !l.valid || age > l.maxAge {
+		var zero T
+		return zero, err
+	}
+
+	return l.value, &StaleError{Age: age, Err: err}
+}
@@ -0,0 +1,61 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastKnownGood(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	lkg := NewLastKnownGood[int](time.Hour)
+	lkg.now = func() time.Time { return now }
+
+	ok := func() (int, error) { return 42, nil }
+	fail := func() (int, error) { return 0, errors.New("server is on fire") }
+
+	got, err := lkg.Fetch(fail)
+	assert.Error(t, err)
+	assert.Equal(t, 0, got)
+
+	_, stale := Staleness(err)
+	assert.False(t, stale)
+
+	got, err = lkg.Fetch(ok)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, got)
+
+	now = now.Add(30 * time.Minute)
+
+	got, err = lkg.Fetch(fail)
+	assert.Error(t, err)
+	assert.Equal(t, 42, got)
+
+	age, stale := Staleness(err)
+	assert.True(t, stale)
+	assert.Equal(t, 30*time.Minute, age)
+
+	now = now.Add(31 * time.Minute)
+
+	got, err = lkg.Fetch(fail)
+	assert.Error(t, err)
+	assert.Equal(t, 0, got)
+
+	_, stale = Staleness(err)
+	assert.False(t, stale)
+}
+
+func TestAnnotateStaleness(t *testing.T) {
+	body := AnnotateStaleness(gin.H{"status": "success"}, nil)
+	assert.Equal(t, gin.H{"status": "success"}, body)
+
+	body = AnnotateStaleness(gin.H{"status": "success"}, &StaleError{
+		Age: 90 * time.Second,
+		Err: errors.New("server is on fire"),
+	})
+	assert.Equal(t, gin.H{"status": "success", "stale": true, "age_seconds": 90}, body)
+}
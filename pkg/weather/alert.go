@@ -0,0 +1,202 @@
+package weather
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Rule notifies Webhook when Metric of a station compares to Threshold using Comparator, and again when it no longer
+// does.
+type Rule struct {
+	Name       string
+	Station    int
+	Metric     string
+	Comparator string
+	Threshold  float64
+	Webhook    string
+}
+
+const (
+	AlertTriggered = "triggered"
+	AlertResolved  = "resolved"
+)
+
+// Alert is the payload delivered to the webhook of a rule.
+type Alert struct {
+	ID          string     `json:"id"`
+	Event       string     `json:"event"`
+	Rule        string     `json:"rule"`
+	Station     int        `json:"station"`
+	StationName string     `json:"station_name"`
+	Metric      string     `json:"metric"`
+	Comparator  string     `json:"comparator"`
+	Threshold   float64    `json:"threshold"`
+	Value       float64    `json:"value"`
+	ReportedAt  *time.Time `json:"reported_at,omitempty"`
+}
+
+var alertMetrics = map[string]func(station *ClimateStation) *float64{
+	"temperature":  func(station *ClimateStation) *float64 { return station.Temperature },
+	"humidity":     func(station *ClimateStation) *float64 { return station.Humidity },
+	"pressure_hpa": func(station *ClimateStation) *float64 { return station.PressureHPA },
+	"precipitation": func(station *ClimateStation) *float64 {
+		if station.Today == nil {
+			return nil
+		}
+
+		return station.Today.Precipitations.Sum
+	},
+	"dew_point": func(station *ClimateStation) *float64 {
+		if station.Derived == nil {
+			return nil
+		}
+
+		return station.Derived.DewPoint
+	},
+	"apparent_temperature": func(station *ClimateStation) *float64 {
+		if station.Derived == nil {
+			return nil
+		}
+
+		return station.Derived.ApparentTemperature
+	},
+}
+
+var alertComparators = map[string]func(value, threshold float64) bool{
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+}
+
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule has no name")
+	}
+
+	if _, exists := alertMetrics[r.Metric]; !exists {
+		return fmt.Errorf("rule %s: unknown metric %q", r.Name, r.Metric)
+	}
+
+	if _, exists := alertComparators[r.Comparator]; !exists {
+		return fmt.Errorf("rule %s: unknown comparator %q", r.Name, r.Comparator)
+	}
+
+	webhook, err := url.Parse(r.Webhook)
+	if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+		return fmt.Errorf("rule %s: webhook must be an http or https URL", r.Name)
+	}
+
+	return nil
+}
+
+// AlertingService wraps a Service and evaluates the rules each time the stations are fetched. Rules are edge
+// triggered: a rule notifies once when its condition starts holding and once when it stops. Stations that don't
+// report the metric keep the rule in its current state.
+type AlertingService struct {
+	service  Service
+	notifier *Notifier
+	rules    []Rule
+
+	mu     sync.Mutex
+	firing map[string]bool
+}
+
+func NewAlertingService(service Service, notifier *Notifier, rules []Rule) (*AlertingService, error) {
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		err := rule.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicated rule %s", rule.Name)
+		}
+
+		names[rule.Name] = true
+	}
+
+	return &AlertingService{
+		service:  service,
+		notifier: notifier,
+		rules:    rules,
+		firing:   make(map[string]bool),
+	}, nil
+}
+
+func (s *AlertingService) GetClimateStations(ctx context.Context) ([]*ClimateStation, error) {
+	stations, err := s.service.GetClimateStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.evaluate(stations)
+
+	return stations, nil
+}
+
+func (s *AlertingService) evaluate(stations []*ClimateStation) {
+	byCode := make(map[int]*ClimateStation, len(stations))
+	for _, station := range stations {
+		byCode[station.Code] = station
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rule := range s.rules {
+		station, found := byCode[rule.Station]
+		if !found {
+			continue
+		}
+
+		value := alertMetrics[rule.Metric](station)
+		if value == nil {
+			continue
+		}
+
+		holds := alertComparators[rule.Comparator](*value, rule.Threshold)
+		if holds == s.firing[rule.Name] {
+			continue
+		}
+
+		s.firing[rule.Name] = holds
+
+		event := AlertResolved
+		if holds {
+			event = AlertTriggered
+		}
+
+		s.notifier.Notify(rule.Webhook, Alert{
+			ID:          newAlertID(),
+			Event:       event,
+			Rule:        rule.Name,
+			Station:     station.Code,
+			StationName: station.Name,
+			Metric:      rule.Metric,
+			Comparator:  rule.Comparator,
+			Threshold:   rule.Threshold,
+			Value:       *value,
+			ReportedAt:  station.LastReport,
+		})
+	}
+}
+
+// newAlertID returns a random ID receivers can use to discard redelivered alerts.
+func newAlertID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		panic(errors.Wrap(err, "unable to generate alert id"))
+	}
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,102 @@
+package weather
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sequenceService struct {
+	responses [][]*ClimateStation
+}
+
+func (s *sequenceService) GetClimateStations(_ context.Context) ([]*ClimateStation, error) {
+	stations := s.responses[0]
+	s.responses = s.responses[1:]
+
+	return stations, nil
+}
+
+func queuedAlerts(notifier *Notifier) []Alert {
+	var alerts []Alert
+	for len(notifier.queue) != 0 {
+		alerts = append(alerts, (<-notifier.queue).alert)
+	}
+
+	return alerts
+}
+
+func TestAlertingServiceEdgeTriggered(t *testing.T) {
+	station := func(temperature *float64) []*ClimateStation {
+		return []*ClimateStation{{Code: 330020, Name: "Quinta Normal, Santiago", Temperature: temperature}}
+	}
+
+	service := &sequenceService{responses: [][]*ClimateStation{
+		station(float64Ptr(2)),
+		station(float64Ptr(-1)),
+		station(float64Ptr(-3)),
+		station(nil),
+		station(float64Ptr(1)),
+	}}
+
+	notifier := NewNotifier(env.NewTestEnv(), "secret", 1, 0)
+	alerting, err := NewAlertingService(service, notifier, []Rule{{
+		Name:       "freezing",
+		Station:    330020,
+		Metric:     "temperature",
+		Comparator: "<",
+		Threshold:  0,
+		Webhook:    "https://example.com/hook",
+	}})
+	if err != nil {
+		t.Fatalf("unable to create service: %v", err)
+	}
+
+	_, _ = alerting.GetClimateStations(context.Background())
+	assert.Empty(t, queuedAlerts(notifier))
+
+	_, _ = alerting.GetClimateStations(context.Background())
+	alerts := queuedAlerts(notifier)
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, AlertTriggered, alerts[0].Event)
+		assert.Equal(t, -1.0, alerts[0].Value)
+		assert.Equal(t, "freezing", alerts[0].Rule)
+		assert.Len(t, alerts[0].ID, 32)
+	}
+
+	// Still firing, and then not reported
+	_, _ = alerting.GetClimateStations(context.Background())
+	_, _ = alerting.GetClimateStations(context.Background())
+	assert.Empty(t, queuedAlerts(notifier))
+
+	_, _ = alerting.GetClimateStations(context.Background())
+	alerts = queuedAlerts(notifier)
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, AlertResolved, alerts[0].Event)
+		assert.Equal(t, 1.0, alerts[0].Value)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := Rule{Name: "rain", Station: 1, Metric: "precipitation", Comparator: ">=", Threshold: 10,
+		Webhook: "https://example.com/hook"}
+	assert.NoError(t, valid.Validate())
+
+	invalid := []Rule{
+		{Station: 1, Metric: "temperature", Comparator: "<", Webhook: "https://example.com"},
+		{Name: "a", Metric: "wind", Comparator: "<", Webhook: "https://example.com"},
+		{Name: "a", Metric: "temperature", Comparator: "=", Webhook: "https://example.com"},
+		{Name: "a", Metric: "temperature", Comparator: "<", Webhook: "ftp://example.com"},
+		{Name: "a", Metric: "temperature", Comparator: "<", Webhook: "/hook"},
+	}
+
+	for _, rule := range invalid {
+		assert.Error(t, rule.Validate(), "%+v", rule)
+	}
+
+	_, err := NewAlertingService(MockService{}, nil, []Rule{valid, valid})
+	assert.Error(t, err, "duplicated name")
+}
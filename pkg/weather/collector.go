@@ -0,0 +1,99 @@
+package weather
+
+import (
+	"context"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+)
+
+// Collector wraps a Service and records the current observations of every operational station into a
+// HistoryStore each time the stations are fetched.
+type Collector struct {
+	env     *env.Env
+	service Service
+	store   *HistoryStore
+}
+
+func NewCollector(env *env.Env, service Service, store *HistoryStore) *Collector {
+	return &Collector{
+		env:     env,
+		service: service,
+		store:   store,
+	}
+}
+
+func (c *Collector) GetClimateStations(ctx context.Context) ([]*ClimateStation, error) {
+	stations, err := c.service.GetClimateStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.record(stations)
+
+	return stations, nil
+}
+
+// Run refreshes the stations every interval until ctx is cancelled, so the history is recorded even without traffic.
+func (c *Collector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshCtx, span := c.env.Tracer().Start(ctx, "weather.Collector.refresh")
+
+		_, err := c.GetClimateStations(refreshCtx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, "unable to refresh stations")
+			c.env.Logger.Warnf("unable to refresh stations for history: %v", err)
+		}
+
+		span.End()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Collector) Observations(code int, from, to time.Time) []Observation {
+	return c.store.Observations(code, from, to)
+}
+
+func (c *Collector) record(stations []*ClimateStation) {
+	for _, station := range stations {
+		if !station.Operational || station.LastReport == nil || station.LastReport.IsZero() {
+			continue
+		}
+
+		obs := Observation{
+			Time:        *station.LastReport,
+			Temperature: station.Temperature,
+			Humidity:    station.Humidity,
+			PressureHPA: station.PressureHPA,
+		}
+
+		if station.Today != nil {
+			obs.Precipitation = station.Today.Precipitations.Sum
+		}
+
+		if obs.Temperature == nil && obs.Humidity == nil && obs.PressureHPA == nil && obs.Precipitation == nil {
+			continue
+		}
+
+		_, err := c.store.Add(station.Code, obs)
+		if err != nil {
+			c.env.Logger.Errorf("unable to record observation of station %d: %v", station.Code, err)
+		}
+	}
+
+	err := c.store.Compact()
+	if err != nil {
+		c.env.Logger.Errorf("unable to compact the history: %v", err)
+	}
+}
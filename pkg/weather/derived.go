@@ -0,0 +1,78 @@
+package weather
+
+import "math"
+
+// DerivedMetrics are computed from the temperature and relative humidity of a station. Meteochile doesn't report
+// wind speed, so the apparent temperature doesn't account for wind chill.
+type DerivedMetrics struct {
+	DewPoint            *float64 `json:"dew_point"`
+	HeatIndex           *float64 `json:"heat_index"`
+	ApparentTemperature *float64 `json:"apparent_temperature"`
+	AbsoluteHumidity    *float64 `json:"absolute_humidity"`
+}
+
+// heatIndexThreshold is the temperature in °C below which the heat index isn't defined.
+const heatIndexThreshold = 26.7
+
+func deriveMetrics(stations []*ClimateStation) {
+	for _, station := range stations {
+		if station.Temperature == nil || station.Humidity == nil || *station.Humidity <= 0 {
+			continue
+		}
+
+		temperature, humidity := *station.Temperature, *station.Humidity
+
+		derived := &DerivedMetrics{
+			DewPoint:            float64Ptr(round(dewPoint(temperature, humidity), 1)),
+			ApparentTemperature: float64Ptr(temperature),
+			AbsoluteHumidity:    float64Ptr(round(absoluteHumidity(temperature, humidity), 2)),
+		}
+
+		if temperature >= heatIndexThreshold {
+			derived.HeatIndex = float64Ptr(round(heatIndex(temperature, humidity), 1))
+			derived.ApparentTemperature = derived.HeatIndex
+		}
+
+		station.Derived = derived
+	}
+}
+
+// dewPoint uses the Magnus formula with the Alduchov and Eskridge coefficients. The temperature is in °C and the
+// humidity a ratio.
+func dewPoint(celsius, humidity float64) float64 {
+	const a, b = 17.625, 243.04
+
+	gamma := math.Log(humidity) + a*celsius/(b+celsius)
+	return b * gamma / (a - gamma)
+}
+
+// heatIndex implements the NWS algorithm, the Rothfusz regression with its low and high humidity adjustments. The
+// temperature is in °C and the humidity a ratio.
+func heatIndex(celsius, humidity float64) float64 {
+	t := celsius*9/5 + 32
+	rh := humidity * 100
+
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+			0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+		if rh < 13 && t >= 80 && t <= 112 {
+			hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		} else if rh > 85 && t >= 80 && t <= 87 {
+			hi += (rh - 85) / 10 * (87 - t) / 5
+		}
+	}
+
+	return (hi - 32) * 5 / 9
+}
+
+// absoluteHumidity returns the water vapour density in g/m³. The temperature is in °C and the humidity a ratio.
+func absoluteHumidity(celsius, humidity float64) float64 {
+	saturation := 6.112 * math.Exp(17.67*celsius/(celsius+243.5))
+	return saturation * humidity * 100 * 2.1674 / (273.15 + celsius)
+}
+
+func float64Ptr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,93 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeatIndexReference(t *testing.T) {
+	// NWS heat index chart, in °F and relative humidity percent. The chart is rounded to the degree
+	cases := []struct {
+		temperature, humidity, expected float64
+	}{
+		{80, 40, 80},
+		{90, 50, 95},
+		{100, 40, 109},
+		{86, 90, 105},
+		{96, 60, 116},
+		{104, 40, 119},
+		{92, 75, 116},
+	}
+
+	for _, tc := range cases {
+		got := heatIndex((tc.temperature-32)*5/9, tc.humidity/100)*9/5 + 32
+		assert.InDelta(t, tc.expected, got, 0.6, "%v°F at %v%%", tc.temperature, tc.humidity)
+	}
+}
+
+func TestDewPointReference(t *testing.T) {
+	cases := []struct {
+		temperature, humidity, expected float64
+	}{
+		{20, 0.5, 9.3},
+		{25, 0.6, 16.7},
+		{30, 0.7, 23.9},
+		{0, 0.8, -3.0},
+		{10, 1, 10},
+	}
+
+	for _, tc := range cases {
+		assert.InDelta(t, tc.expected, dewPoint(tc.temperature, tc.humidity), 0.1, "%v°C at %v", tc.temperature, tc.humidity)
+	}
+}
+
+func TestAbsoluteHumidityReference(t *testing.T) {
+	// Saturation vapour density tables, in g/m³
+	cases := []struct {
+		temperature, humidity, expected float64
+	}{
+		{0, 1, 4.85},
+		{20, 1, 17.3},
+		{30, 1, 30.4},
+		{20, 0.5, 8.65},
+	}
+
+	for _, tc := range cases {
+		assert.InDelta(t, tc.expected, absoluteHumidity(tc.temperature, tc.humidity), 0.1, "%v°C at %v", tc.temperature, tc.humidity)
+	}
+}
+
+func TestDeriveMetrics(t *testing.T) {
+	hot := &ClimateStation{Temperature: float64Ptr(35), Humidity: float64Ptr(0.5)}
+	mild := &ClimateStation{Temperature: float64Ptr(20), Humidity: float64Ptr(0.5)}
+	missing := &ClimateStation{Temperature: float64Ptr(20)}
+	dry := &ClimateStation{Temperature: float64Ptr(20), Humidity: float64Ptr(0)}
+
+	deriveMetrics([]*ClimateStation{hot, mild, missing, dry})
+
+	assert.Equal(t, float64Ptr(40.7), hot.Derived.HeatIndex)
+	assert.Equal(t, hot.Derived.HeatIndex, hot.Derived.ApparentTemperature)
+	assert.Equal(t, float64Ptr(23), hot.Derived.DewPoint)
+
+	assert.Nil(t, mild.Derived.HeatIndex)
+	assert.Equal(t, float64Ptr(20), mild.Derived.ApparentTemperature)
+	assert.Equal(t, float64Ptr(9.3), mild.Derived.DewPoint)
+	assert.Equal(t, float64Ptr(8.64), mild.Derived.AbsoluteHumidity)
+
+	assert.Nil(t, missing.Derived)
+	assert.Nil(t, dry.Derived)
+}
+
+func TestDerivedMetricsUnits(t *testing.T) {
+	station := &ClimateStation{Temperature: float64Ptr(20), Humidity: float64Ptr(0.5)}
+	deriveMetrics([]*ClimateStation{station})
+
+	got := unitSystems["imperial"].convertStation(station)
+	assert.Equal(t, float64Ptr(48.74), got.Derived.DewPoint)
+	assert.Equal(t, float64Ptr(68), got.Derived.ApparentTemperature)
+	assert.Equal(t, float64Ptr(3.78), got.Derived.AbsoluteHumidity)
+	assert.Nil(t, got.Derived.HeatIndex)
+
+	assert.Equal(t, float64Ptr(9.3), station.Derived.DewPoint)
+}
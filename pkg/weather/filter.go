@@ -0,0 +1,252 @@
+package weather
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stationQuery holds the filters, sorting and pagination of a station listing. Every filter is optional and they
+// are combined with AND.
+type stationQuery struct {
+	region         string
+	commune        string
+	operational    *bool
+	minTemperature *float64
+	maxTemperature *float64
+	reportedWithin time.Duration
+
+	units unitSystem
+
+	sortKey  string
+	sortDesc bool
+
+	page    int
+	perPage int
+}
+
+// maxReportedWithin bounds reported_within, so it can't overflow a time.Duration.
+const maxReportedWithin = 366 * 24 * time.Hour
+
+type Pagination struct {
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+	Total   int `json:"total"`
+}
+
+var stationSorters = map[string]func(a, b *ClimateStation) bool{
+	"code":        func(a, b *ClimateStation) bool { return a.Code < b.Code },
+	"name":        func(a, b *ClimateStation) bool { return removeTilde(a.Name) < removeTilde(b.Name) },
+	"temperature": func(a, b *ClimateStation) bool { return *a.Temperature < *b.Temperature },
+	"humidity":    func(a, b *ClimateStation) bool { return *a.Humidity < *b.Humidity },
+	"pressure":    func(a, b *ClimateStation) bool { return *a.PressureHPA < *b.PressureHPA },
+	"last_report": func(a, b *ClimateStation) bool { return reportTime(a).Before(reportTime(b)) },
+}
+
+// nullableSortValues returns the value of the sort keys that might not be reported. Stations without the value are
+// always sorted last, and the sorter is only called when both stations have it.
+var nullableSortValues = map[string]func(station *ClimateStation) *float64{
+	"temperature": func(station *ClimateStation) *float64 { return station.Temperature },
+	"humidity":    func(station *ClimateStation) *float64 { return station.Humidity },
+	"pressure":    func(station *ClimateStation) *float64 { return station.PressureHPA },
+}
+
+// parseStationQuery reads the listing parameters of the request. The returned map holds an error message for every
+// invalid parameter.
+func parseStationQuery(c *gin.Context) (stationQuery, gin.H) {
+	errs := gin.H{}
+	query := stationQuery{
+		region:  c.Query("region"),
+		commune: c.Query("commune"),
+		page:    1,
+	}
+
+	units, valid := parseUnitSystem(c)
+	if !valid {
+		errs["units"] = "units must be one of metric, imperial or si"
+	}
+
+	query.units = units
+
+	if param := c.Query("operational"); param != "" {
+		operational, err := strconv.ParseBool(param)
+		if err != nil {
+			errs["operational"] = "operational must be true or false"
+		}
+
+		query.operational = &operational
+	}
+
+	if param := c.Query("min_temperature"); param != "" {
+		min, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			errs["min_temperature"] = "min_temperature must be numeric"
+		}
+
+		query.minTemperature = &min
+	}
+
+	if param := c.Query("max_temperature"); param != "" {
+		max, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			errs["max_temperature"] = "max_temperature must be numeric"
+		}
+
+		query.maxTemperature = &max
+	}
+
+	if param := c.Query("reported_within"); param != "" {
+		minutes, err := strconv.Atoi(param)
+		if err != nil || minutes < 1 || minutes > int(maxReportedWithin/time.Minute) {
+			errs["reported_within"] = "reported_within must be a positive amount of minutes up to a year"
+			minutes = 0
+		}
+
+		query.reportedWithin = time.Duration(minutes) * time.Minute
+	}
+
+	if param := c.Query("sort"); param != "" {
+		query.sortKey = strings.TrimPrefix(param, "-")
+		query.sortDesc = strings.HasPrefix(param, "-")
+
+		if _, exists := stationSorters[query.sortKey]; !exists {
+			errs["sort"] = "sort must be one of code, name, temperature, humidity, pressure or last_report, " +
+				"optionally prefixed by - for descending order"
+		}
+	}
+
+	if param := c.Query("page"); param != "" {
+		page, err := strconv.Atoi(param)
+		if err != nil || page < 1 {
+			errs["page"] = "page must be a positive integer"
+		}
+
+		query.page = page
+	}
+
+	if param := c.Query("per_page"); param != "" {
+		perPage, err := strconv.Atoi(param)
+		if err != nil || perPage < 1 {
+			errs["per_page"] = "per_page must be a positive integer"
+		}
+
+		query.perPage = perPage
+	}
+
+	return query, errs
+}
+
+func (q stationQuery) filter(stations []*ClimateStation, now time.Time) []*ClimateStation {
+	var results []*ClimateStation
+	for _, station := range stations {
+		if q.matches(station, now) {
+			results = append(results, station)
+		}
+	}
+
+	return results
+}
+
+func (q stationQuery) matches(station *ClimateStation, now time.Time) bool {
+	if q.region != "" && (station.Location == nil || !sameName(station.Location.Region, q.region)) {
+		return false
+	}
+
+	if q.commune != "" && (station.Location == nil || !sameName(station.Location.Commune, q.commune)) {
+		return false
+	}
+
+	if q.operational != nil && station.Operational != *q.operational {
+		return false
+	}
+
+	// Stations without a temperature reading can't satisfy a temperature filter
+	if (q.minTemperature != nil || q.maxTemperature != nil) && station.Temperature == nil {
+		return false
+	}
+
+	if q.minTemperature != nil && *station.Temperature < *q.minTemperature {
+		return false
+	}
+
+	if q.maxTemperature != nil && *station.Temperature > *q.maxTemperature {
+		return false
+	}
+
+	if q.reportedWithin != 0 && (station.LastReport == nil || now.Sub(*station.LastReport) > q.reportedWithin) {
+		return false
+	}
+
+	return true
+}
+
+func (q stationQuery) sort(stations []*ClimateStation) {
+	less, exists := stationSorters[q.sortKey]
+	if !exists {
+		return
+	}
+
+	value := nullableSortValues[q.sortKey]
+
+	sort.SliceStable(stations, func(i, j int) bool {
+		if value != nil {
+			missingI, missingJ := value(stations[i]) == nil, value(stations[j]) == nil
+			if missingI || missingJ {
+				return !missingI && missingJ
+			}
+		}
+
+		if q.sortDesc {
+			return less(stations[j], stations[i])
+		}
+
+		return less(stations[i], stations[j])
+	})
+}
+
+// paginate returns the requested page of stations. Without per_page every station is returned in a single page.
+func (q stationQuery) paginate(stations []*ClimateStation) ([]*ClimateStation, *Pagination) {
+	if q.perPage == 0 {
+		return stations, nil
+	}
+
+	pagination := &Pagination{
+		Page:    q.page,
+		PerPage: q.perPage,
+		Total:   len(stations),
+	}
+
+	// Compared before multiplying, since large pages would overflow the offset
+	if len(stations) == 0 || q.page-1 > (len(stations)-1)/q.perPage {
+		return []*ClimateStation{}, pagination
+	}
+
+	start := (q.page - 1) * q.perPage
+
+	end := len(stations)
+	if q.perPage < end-start {
+		end = start + q.perPage
+	}
+
+	return stations[start:end], pagination
+}
+
+func (q stationQuery) filtered() bool {
+	return q.region != "" || q.commune != "" || q.operational != nil || q.minTemperature != nil ||
+		q.maxTemperature != nil || q.reportedWithin != 0
+}
+
+func sameName(a, b string) bool {
+	return strings.EqualFold(removeTilde(strings.TrimSpace(a)), removeTilde(strings.TrimSpace(b)))
+}
+
+func reportTime(station *ClimateStation) time.Time {
+	if station.LastReport == nil {
+		return time.Time{}
+	}
+
+	return *station.LastReport
+}
@@ -0,0 +1,122 @@
+package weather
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func queryContext(query string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse(query)
+
+	return ctx
+}
+
+func filterTestStations(now time.Time) []*ClimateStation {
+	recent := now.Add(-10 * time.Minute)
+	old := now.Add(-3 * time.Hour)
+
+	return []*ClimateStation{
+		{Code: 330020, Name: "Quinta Normal, Santiago", Operational: true, Temperature: float64Ptr(18), LastReport: &recent},
+		{Code: 330021, Name: "Pudahuel Santiago", Operational: true, Temperature: float64Ptr(21), LastReport: &old},
+		{Code: 330122, Name: "Aguas Andinas, La Florida", Operational: false},
+		{Code: 520006, Name: "Carlos Ibañez, Punta Arenas Ap.", Operational: true, Temperature: float64Ptr(-2), LastReport: &recent},
+	}
+}
+
+func codes(stations []*ClimateStation) []int {
+	var result []int
+	for _, station := range stations {
+		result = append(result, station.Code)
+	}
+
+	return result
+}
+
+func TestStationQueryFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+	stations := filterTestStations(now)
+	locateStations(stations)
+
+	cases := map[string][]int{
+		"":                      {330020, 330021, 330122, 520006},
+		"?region=metropolitana": {330020, 330021, 330122},
+		"?commune=PUNTA ARENAS": {520006},
+		"?operational=false":    {330122},
+		"?min_temperature=0":    {330020, 330021},
+		"?max_temperature=20":   {330020, 520006},
+		"?reported_within=30":   {330020, 520006},
+		"?region=Metropolitana&reported_within=30&operational=true": {330020},
+		"?region=Ñuble": nil,
+	}
+
+	for query, expected := range cases {
+		q, errs := parseStationQuery(queryContext(query))
+		assert.Empty(t, errs, query)
+		assert.Equal(t, expected, codes(q.filter(stations, now)), query)
+	}
+}
+
+func TestStationQueryInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	_, errs := parseStationQuery(queryContext(
+		"?operational=maybe&min_temperature=a&max_temperature=b&reported_within=0&sort=color&page=0&per_page=-1"))
+
+	assert.Len(t, errs, 7)
+
+	q, errs := parseStationQuery(queryContext("?reported_within=9223372036854775807"))
+	assert.Contains(t, errs, "reported_within")
+	assert.Zero(t, q.reportedWithin)
+}
+
+func TestStationQuerySortAndPaginate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	q, errs := parseStationQuery(queryContext("?sort=-temperature&page=2&per_page=3"))
+	assert.Empty(t, errs)
+
+	stations := q.filter(filterTestStations(now), now)
+	q.sort(stations)
+	// Stations without a reading are last regardless of the direction
+	assert.Equal(t, []int{330021, 330020, 520006, 330122}, codes(stations))
+
+	page, pagination := q.paginate(stations)
+	assert.Equal(t, []int{330122}, codes(page))
+	assert.Equal(t, &Pagination{Page: 2, PerPage: 3, Total: 4}, pagination)
+
+	q, _ = parseStationQuery(queryContext("?sort=name&page=3&per_page=3"))
+	q.sort(stations)
+	assert.Equal(t, []int{330122, 520006, 330021, 330020}, codes(stations))
+
+	page, _ = q.paginate(stations)
+	assert.Empty(t, page)
+
+	// The offset of such a page overflows an int
+	q, errs = parseStationQuery(queryContext("?page=4294967296&per_page=4294967296"))
+	assert.Empty(t, errs)
+
+	page, _ = q.paginate(stations)
+	assert.Empty(t, page)
+
+	q, _ = parseStationQuery(queryContext("?per_page=9223372036854775807"))
+	page, _ = q.paginate(stations)
+	assert.Len(t, page, 4)
+
+	q, _ = parseStationQuery(queryContext(""))
+	page, pagination = q.paginate(stations)
+	assert.Len(t, page, 4)
+	assert.Nil(t, pagination)
+}
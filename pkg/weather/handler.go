@@ -1,26 +1,37 @@
 package weather
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ccuetoh/libreapi/pkg/env"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Service interface {
-	GetClimateStations() ([]*ClimateStation, error)
+	GetClimateStations(ctx context.Context) ([]*ClimateStation, error)
+}
+
+// ObservationSource provides the recorded observations of a station, such as a Collector.
+type ObservationSource interface {
+	Observations(code int, from, to time.Time) []Observation
 }
 
 type Handler struct {
 	env     *env.Env
 	service Service
+	now     func() time.Time
 }
 
 func NewHandler(env *env.Env, service Service) *Handler {
 	return &Handler{
 		env:     env,
 		service: service,
+		now:     time.Now,
 	}
 }
 
@@ -41,66 +52,328 @@ func (h *Handler) Stations() gin.HandlerFunc {
 			return
 		}
 
-		stations, err := h.service.GetClimateStations()
-		if err != nil {
-			c.JSON(500, gin.H{
+		query, errs := parseStationQuery(c)
+		if len(errs) != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"status": "error",
-				"errors": gin.H{
-					"fetch": "unable to fetch data",
-				},
+				"errors": errs,
 			})
 
-			h.env.Log(c).Errorf("unable to fetch data: %v", err)
+			h.env.Log(c).Trace("bad query")
 			return
 		}
 
-		if name != "" {
-			stationsFound := searchStationName(stations, name)
-			if len(stationsFound) == 0 {
-				c.JSON(http.StatusNotFound, gin.H{
+		stations, err := h.service.GetClimateStations(c)
+		if _, stale := upstream.Staleness(err); err != nil && !stale {
+			h.fetchError(c, err)
+			return
+		}
+
+		if err != nil {
+			_ = c.Error(err)
+			h.env.Log(c).WithError(err).Warn("serving stale data")
+		}
+
+		// Filters are applied to the converted values, so thresholds are given in the requested units
+		stations = query.units.convert(stations)
+
+		if code != "" {
+			match, found := searchStationCode(stations, code)
+			if !found {
+				c.JSON(http.StatusNotFound, upstream.AnnotateStaleness(gin.H{
 					"status": "success",
 					"data":   nil,
-				})
+				}, err))
 
 				h.env.Log(c).Trace("ok (none)")
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{
+			c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{
 				"status": "success",
-				"data":   stationsFound,
-			})
+				"data":   match,
+				"units":  query.units.units,
+			}, err))
 
 			h.env.Log(c).Trace("ok")
 			return
 		}
 
-		if code != "" {
-			match, found := searchStationCode(stations, code)
-			if !found {
-				c.JSON(http.StatusNotFound, gin.H{
-					"status": "success",
-					"data":   nil,
+		if name != "" {
+			stations = searchStationName(stations, name)
+		}
+
+		stations = query.filter(stations, h.now())
+		if len(stations) == 0 && (name != "" || query.filtered()) {
+			c.JSON(http.StatusNotFound, upstream.AnnotateStaleness(gin.H{
+				"status": "success",
+				"data":   nil,
+			}, err))
+
+			h.env.Log(c).Trace("ok (none)")
+			return
+		}
+
+		query.sort(stations)
+		page, pagination := query.paginate(stations)
+
+		body := gin.H{
+			"status": "success",
+			"data":   page,
+			"units":  query.units.units,
+		}
+
+		if pagination != nil {
+			body["pagination"] = pagination
+		}
+
+		c.JSON(http.StatusOK, upstream.AnnotateStaleness(body, err))
+
+		h.env.Log(c).Trace("ok")
+	}
+}
+
+func (h *Handler) fetchError(c *gin.Context, err error) {
+	upstream.RespondFailure(c, h.env.Log(c), err, "unable to fetch data", func(message string) gin.H {
+		return gin.H{
+			"status": "error",
+			"errors": gin.H{
+				"fetch": message,
+			},
+		}
+	})
+}
+
+func (h *Handler) Nearest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": gin.H{
+					"lat": "lat must be a number between -90 and 90",
+				},
+			})
+
+			h.env.Log(c).Trace("bad lat")
+			return
+		}
+
+		lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": gin.H{
+					"lon": "lon must be a number between -180 and 180",
+				},
+			})
+
+			h.env.Log(c).Trace("bad lon")
+			return
+		}
+
+		limit := 5
+		limitParam := c.Query("limit")
+		if limitParam != "" {
+			limit, err = strconv.Atoi(limitParam)
+			if err != nil || limit < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status": "error",
+					"errors": gin.H{
+						"limit": "limit must be a positive integer",
+					},
 				})
 
-				h.env.Log(c).Trace("ok (none)")
+				h.env.Log(c).Trace("bad limit")
 				return
 			}
+		}
 
-			c.JSON(http.StatusOK, gin.H{
+		units, valid := parseUnitSystem(c)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": gin.H{
+					"units": "units must be one of metric, imperial or si",
+				},
+			})
+
+			h.env.Log(c).Trace("bad units")
+			return
+		}
+
+		stations, err := h.service.GetClimateStations(c)
+		if _, stale := upstream.Staleness(err); err != nil && !stale {
+			h.fetchError(c, err)
+			return
+		}
+
+		if err != nil {
+			_ = c.Error(err)
+			h.env.Log(c).WithError(err).Warn("serving stale data")
+		}
+
+		c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{
+			"status": "success",
+			"data":   nearestStations(units.convert(stations), lat, lon, limit),
+			"units":  units.units,
+		}, err))
+
+		h.env.Log(c).Trace("ok")
+	}
+}
+
+func (h *Handler) History(source ObservationSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code, err := strconv.Atoi(c.Param("code"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": gin.H{
+					"code": "code must be an integer",
+				},
+			})
+
+			h.env.Log(c).Trace("bad code")
+			return
+		}
+
+		var from time.Time
+		to := h.now()
+		resolution := time.Hour
+		errs := gin.H{}
+
+		if param := c.Query("to"); param != "" {
+			to, err = time.Parse(time.RFC3339, param)
+			if err != nil {
+				errs["to"] = "to must be an RFC 3339 timestamp"
+			}
+		}
+
+		if param := c.Query("from"); param != "" {
+			from, err = time.Parse(time.RFC3339, param)
+			if err != nil {
+				errs["from"] = "from must be an RFC 3339 timestamp"
+			}
+		} else {
+			from = to.Add(-24 * time.Hour)
+		}
+
+		if param := c.Query("resolution"); param != "" {
+			resolution, err = time.ParseDuration(param)
+			if err != nil || resolution < time.Minute {
+				errs["resolution"] = "resolution must be a duration of at least 1m"
+			}
+		}
+
+		if len(errs) == 0 && !from.Before(to) {
+			errs["range"] = "from must be before to"
+		}
+
+		if len(errs) != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": errs,
+			})
+
+			h.env.Log(c).Trace("bad query")
+			return
+		}
+
+		observations := source.Observations(code, from, to)
+		if len(observations) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
 				"status": "success",
-				"data":   match,
+				"data":   nil,
 			})
 
-			h.env.Log(c).Trace("ok")
+			h.env.Log(c).Trace("ok (none)")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"status": "success",
-			"data":   stations,
+			"data":   aggregateObservations(observations, resolution),
 		})
 
 		h.env.Log(c).Trace("ok")
 	}
 }
+
+func (h *Handler) Precipitation(source ObservationSource, normals PrecipitationNormals) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code, err := strconv.Atoi(c.Param("code"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": gin.H{
+					"code": "code must be an integer",
+				},
+			})
+
+			h.env.Log(c).Trace("bad code")
+			return
+		}
+
+		now := h.now()
+
+		observations := source.Observations(code, time.Time{}, now.Add(time.Second))
+		if !hasPrecipitation(observations) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status": "success",
+				"data":   nil,
+			})
+
+			h.env.Log(c).Trace("ok (none)")
+			return
+		}
+
+		var stationNormals *[12]float64
+		if monthly, exists := normals[code]; exists {
+			stationNormals = &monthly
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   precipitationSummary(observations, stationNormals, now),
+		})
+
+		h.env.Log(c).Trace("ok")
+	}
+}
+
+func (h *Handler) Summary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		units, valid := parseUnitSystem(c)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": "error",
+				"errors": gin.H{
+					"units": "units must be one of metric, imperial or si",
+				},
+			})
+
+			h.env.Log(c).Trace("bad units")
+			return
+		}
+
+		stations, err := h.service.GetClimateStations(c)
+		if _, stale := upstream.Staleness(err); err != nil && !stale {
+			h.fetchError(c, err)
+			return
+		}
+
+		if err != nil {
+			_ = c.Error(err)
+			h.env.Log(c).WithError(err).Warn("serving stale data")
+		}
+
+		c.JSON(http.StatusOK, upstream.AnnotateStaleness(gin.H{
+			"status": "success",
+			"data":   summarizeRegions(units.convert(stations)),
+			"units":  units.units,
+		}, err))
+
+		h.env.Log(c).Trace("ok")
+	}
+}
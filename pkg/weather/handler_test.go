@@ -1,6 +1,7 @@
 package weather
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/ccuetoh/libreapi/internal/test"
 	"github.com/ccuetoh/libreapi/pkg/env"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -20,7 +22,7 @@ type MockService struct {
 	stationsErr error
 }
 
-func (s MockService) GetClimateStations() ([]*ClimateStation, error) {
+func (s MockService) GetClimateStations(_ context.Context) ([]*ClimateStation, error) {
 	return s.stations, s.stationsErr
 }
 
@@ -38,35 +40,35 @@ func TestStationsOk(t *testing.T) {
 			Name:        "test1",
 			Operational: true,
 			LastReport:  &today,
-			Temperature: 10,
-			Humidity:    20,
-			PressureHPA: 30,
+			Temperature: float64Ptr(10),
+			Humidity:    float64Ptr(20),
+			PressureHPA: float64Ptr(30),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        today,
-					Temperature: 40,
+					Time:        &today,
+					Temperature: float64Ptr(40),
 				},
 				Minimum: Measurement{
-					Time:        today,
-					Temperature: 50,
+					Time:        &today,
+					Temperature: float64Ptr(50),
 				},
 				Precipitations: Precipitations{
-					Sum: 60,
-					EMA: 70,
+					Sum: float64Ptr(60),
+					EMA: float64Ptr(70),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterday,
-					Temperature: 80,
+					Time:        &yesterday,
+					Temperature: float64Ptr(80),
 				},
 				Minimum: Measurement{
-					Time:        yesterday,
-					Temperature: 90,
+					Time:        &yesterday,
+					Temperature: float64Ptr(90),
 				},
 				Precipitations: Precipitations{
-					Sum: 100,
-					EMA: 110,
+					Sum: float64Ptr(100),
+					EMA: float64Ptr(110),
 				},
 			},
 		},
@@ -98,6 +100,54 @@ func TestStationsError(t *testing.T) {
 	test.AssertResponseBodySlice(t, recorder, nil)
 }
 
+func TestStationsStale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := []*ClimateStation{
+		{
+			Code:        1,
+			Name:        "test1",
+			Operational: false,
+		},
+	}
+
+	service := MockService{
+		stations: data,
+		stationsErr: &upstream.StaleError{
+			Age: 2 * time.Minute,
+			Err: errors.New("server is on fire"),
+		},
+	}
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"stale":true`)
+	assert.Contains(t, recorder.Body.String(), `"age_seconds":120`)
+}
+
+func TestStationsUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := MockService{stationsErr: &upstream.OpenError{
+		Source:     "meteochile",
+		RetryAfter: 30 * time.Second,
+	}}
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, "30", recorder.Header().Get("Retry-After"))
+}
+
 func TestStationsInvalidQuery(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -112,35 +162,35 @@ func TestStationsInvalidQuery(t *testing.T) {
 			Name:        "test1",
 			Operational: true,
 			LastReport:  &today,
-			Temperature: 10,
-			Humidity:    20,
-			PressureHPA: 30,
+			Temperature: float64Ptr(10),
+			Humidity:    float64Ptr(20),
+			PressureHPA: float64Ptr(30),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        today,
-					Temperature: 40,
+					Time:        &today,
+					Temperature: float64Ptr(40),
 				},
 				Minimum: Measurement{
-					Time:        today,
-					Temperature: 50,
+					Time:        &today,
+					Temperature: float64Ptr(50),
 				},
 				Precipitations: Precipitations{
-					Sum: 60,
-					EMA: 70,
+					Sum: float64Ptr(60),
+					EMA: float64Ptr(70),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterday,
-					Temperature: 80,
+					Time:        &yesterday,
+					Temperature: float64Ptr(80),
 				},
 				Minimum: Measurement{
-					Time:        yesterday,
-					Temperature: 90,
+					Time:        &yesterday,
+					Temperature: float64Ptr(90),
 				},
 				Precipitations: Precipitations{
-					Sum: 100,
-					EMA: 110,
+					Sum: float64Ptr(100),
+					EMA: float64Ptr(110),
 				},
 			},
 		},
@@ -175,35 +225,35 @@ func TestStationsNameQuery(t *testing.T) {
 			Name:        "test1",
 			Operational: true,
 			LastReport:  &today,
-			Temperature: 10,
-			Humidity:    20,
-			PressureHPA: 30,
+			Temperature: float64Ptr(10),
+			Humidity:    float64Ptr(20),
+			PressureHPA: float64Ptr(30),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        today,
-					Temperature: 40,
+					Time:        &today,
+					Temperature: float64Ptr(40),
 				},
 				Minimum: Measurement{
-					Time:        today,
-					Temperature: 50,
+					Time:        &today,
+					Temperature: float64Ptr(50),
 				},
 				Precipitations: Precipitations{
-					Sum: 60,
-					EMA: 70,
+					Sum: float64Ptr(60),
+					EMA: float64Ptr(70),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterday,
-					Temperature: 80,
+					Time:        &yesterday,
+					Temperature: float64Ptr(80),
 				},
 				Minimum: Measurement{
-					Time:        yesterday,
-					Temperature: 90,
+					Time:        &yesterday,
+					Temperature: float64Ptr(90),
 				},
 				Precipitations: Precipitations{
-					Sum: 100,
-					EMA: 110,
+					Sum: float64Ptr(100),
+					EMA: float64Ptr(110),
 				},
 			},
 		},
@@ -237,35 +287,35 @@ func TestStationsNameQueryNotFound(t *testing.T) {
 			Name:        "test1",
 			Operational: true,
 			LastReport:  &today,
-			Temperature: 10,
-			Humidity:    20,
-			PressureHPA: 30,
+			Temperature: float64Ptr(10),
+			Humidity:    float64Ptr(20),
+			PressureHPA: float64Ptr(30),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        today,
-					Temperature: 40,
+					Time:        &today,
+					Temperature: float64Ptr(40),
 				},
 				Minimum: Measurement{
-					Time:        today,
-					Temperature: 50,
+					Time:        &today,
+					Temperature: float64Ptr(50),
 				},
 				Precipitations: Precipitations{
-					Sum: 60,
-					EMA: 70,
+					Sum: float64Ptr(60),
+					EMA: float64Ptr(70),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterday,
-					Temperature: 80,
+					Time:        &yesterday,
+					Temperature: float64Ptr(80),
 				},
 				Minimum: Measurement{
-					Time:        yesterday,
-					Temperature: 90,
+					Time:        &yesterday,
+					Temperature: float64Ptr(90),
 				},
 				Precipitations: Precipitations{
-					Sum: 100,
-					EMA: 110,
+					Sum: float64Ptr(100),
+					EMA: float64Ptr(110),
 				},
 			},
 		},
@@ -300,35 +350,35 @@ func TestStationsCodeQuery(t *testing.T) {
 			Name:        "test1",
 			Operational: true,
 			LastReport:  &today,
-			Temperature: 10,
-			Humidity:    20,
-			PressureHPA: 30,
+			Temperature: float64Ptr(10),
+			Humidity:    float64Ptr(20),
+			PressureHPA: float64Ptr(30),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        today,
-					Temperature: 40,
+					Time:        &today,
+					Temperature: float64Ptr(40),
 				},
 				Minimum: Measurement{
-					Time:        today,
-					Temperature: 50,
+					Time:        &today,
+					Temperature: float64Ptr(50),
 				},
 				Precipitations: Precipitations{
-					Sum: 60,
-					EMA: 70,
+					Sum: float64Ptr(60),
+					EMA: float64Ptr(70),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterday,
-					Temperature: 80,
+					Time:        &yesterday,
+					Temperature: float64Ptr(80),
 				},
 				Minimum: Measurement{
-					Time:        yesterday,
-					Temperature: 90,
+					Time:        &yesterday,
+					Temperature: float64Ptr(90),
 				},
 				Precipitations: Precipitations{
-					Sum: 100,
-					EMA: 110,
+					Sum: float64Ptr(100),
+					EMA: float64Ptr(110),
 				},
 			},
 		},
@@ -362,35 +412,35 @@ func TestStationsCodeQueryNotFound(t *testing.T) {
 			Name:        "test1",
 			Operational: true,
 			LastReport:  &today,
-			Temperature: 10,
-			Humidity:    20,
-			PressureHPA: 30,
+			Temperature: float64Ptr(10),
+			Humidity:    float64Ptr(20),
+			PressureHPA: float64Ptr(30),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        today,
-					Temperature: 40,
+					Time:        &today,
+					Temperature: float64Ptr(40),
 				},
 				Minimum: Measurement{
-					Time:        today,
-					Temperature: 50,
+					Time:        &today,
+					Temperature: float64Ptr(50),
 				},
 				Precipitations: Precipitations{
-					Sum: 60,
-					EMA: 70,
+					Sum: float64Ptr(60),
+					EMA: float64Ptr(70),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterday,
-					Temperature: 80,
+					Time:        &yesterday,
+					Temperature: float64Ptr(80),
 				},
 				Minimum: Measurement{
-					Time:        yesterday,
-					Temperature: 90,
+					Time:        &yesterday,
+					Temperature: float64Ptr(90),
 				},
 				Precipitations: Precipitations{
-					Sum: 100,
-					EMA: 110,
+					Sum: float64Ptr(100),
+					EMA: float64Ptr(110),
 				},
 			},
 		},
@@ -410,3 +460,313 @@ func TestStationsCodeQueryNotFound(t *testing.T) {
 	assert.Equal(t, recorder.Code, http.StatusNotFound)
 	test.AssertResponseBodySlice(t, recorder, nil)
 }
+
+func TestNearestOk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := []*ClimateStation{
+		{
+			Code:     330020,
+			Name:     "Quinta Normal, Santiago",
+			Location: stationLocations[330020],
+		},
+		{
+			Code:     520006,
+			Name:     "Carlos Ibañez, Punta Arenas Ap.",
+			Location: stationLocations[520006],
+		},
+	}
+
+	service := MockService{stations: data}
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?lat=-53.1&lon=-70.9&limit=1")
+
+	handler.Nearest()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"code":520006`)
+	assert.NotContains(t, recorder.Body.String(), `"code":330020`)
+}
+
+func TestNearestBadQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+
+	for _, query := range []string{"?lon=-70", "?lat=-100&lon=-70", "?lat=-33&lon=asd", "?lat=-33&lon=-70&limit=0"} {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+
+		ctx.Request = &http.Request{}
+		ctx.Request.URL, _ = url.Parse(query)
+
+		handler.Nearest()(ctx)
+
+		assert.Equal(t, recorder.Code, http.StatusBadRequest, query)
+	}
+}
+
+func TestStationsFilterQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := []*ClimateStation{
+		{Code: 1, Name: "test1", Operational: true, Temperature: float64Ptr(10)},
+		{Code: 2, Name: "test2", Operational: false},
+		{Code: 3, Name: "test3", Operational: true, Temperature: float64Ptr(20)},
+	}
+
+	service := MockService{stations: data}
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?operational=true&sort=-temperature&per_page=1")
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"pagination":{"page":1,"per_page":1,"total":2}`)
+	assert.Contains(t, recorder.Body.String(), `"code":3`)
+	assert.NotContains(t, recorder.Body.String(), `"code":1`)
+}
+
+func TestStationsFilterQueryNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := []*ClimateStation{
+		{Code: 1, Name: "test1", Operational: true, Temperature: float64Ptr(10)},
+	}
+
+	service := MockService{stations: data}
+	handler := NewHandler(env.NewTestEnv(), service)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?min_temperature=30")
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+	test.AssertResponseBodySlice(t, recorder, nil)
+}
+
+func TestStationsBadFilterQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?sort=color")
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+}
+
+func TestHistoryOk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	store := NewHistoryStore(24 * time.Hour)
+	store.now = func() time.Time { return now }
+
+	_, _ = store.Add(1, Observation{Time: now.Add(-3 * time.Hour), Temperature: float64Ptr(10)})
+	_, _ = store.Add(1, Observation{Time: now.Add(-150 * time.Minute), Temperature: float64Ptr(12)})
+	_, _ = store.Add(1, Observation{Time: now.Add(-time.Hour), Temperature: float64Ptr(14)})
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+	handler.now = func() time.Time { return now }
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?resolution=2h")
+	ctx.Params = gin.Params{{Key: "code", Value: "1"}}
+
+	handler.History(store)(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	// Periods start on even hours of Santiago, which is at UTC-3
+	assert.Contains(t, recorder.Body.String(), `"time":"2022-10-29T09:00:00Z","samples":2,"temperature":{"min":10,"max":12,"avg":11}`)
+	assert.Contains(t, recorder.Body.String(), `"time":"2022-10-29T11:00:00Z","samples":1`)
+}
+
+func TestHistoryNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("")
+	ctx.Params = gin.Params{{Key: "code", Value: "1"}}
+
+	handler.History(NewHistoryStore(time.Hour))(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+	test.AssertResponseBodySlice(t, recorder, nil)
+}
+
+func TestHistoryBadQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+
+	cases := []struct {
+		code  string
+		query string
+	}{
+		{"abc", ""},
+		{"1", "?from=yesterday"},
+		{"1", "?to=2022-10-29"},
+		{"1", "?resolution=30s"},
+		{"1", "?resolution=hourly"},
+		{"1", "?from=2022-10-29T12:00:00Z&to=2022-10-29T10:00:00Z"},
+	}
+
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+
+		ctx.Request = &http.Request{}
+		ctx.Request.URL, _ = url.Parse(tc.query)
+		ctx.Params = gin.Params{{Key: "code", Value: tc.code}}
+
+		handler.History(NewHistoryStore(time.Hour))(ctx)
+
+		assert.Equal(t, recorder.Code, http.StatusBadRequest, tc.query)
+	}
+}
+
+func TestStationsUnits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := []*ClimateStation{
+		{Code: 1, Name: "test1", Operational: true, Temperature: float64Ptr(0)},
+		{Code: 2, Name: "test2", Operational: true, Temperature: float64Ptr(-5)},
+	}
+
+	handler := NewHandler(env.NewTestEnv(), MockService{stations: data})
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?units=imperial&min_temperature=30")
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"code":1`)
+	assert.Contains(t, recorder.Body.String(), `"temperature":32`)
+	assert.NotContains(t, recorder.Body.String(), `"code":2`)
+	assert.Contains(t, recorder.Body.String(), `"units":{"system":"imperial","temperature":"°F","pressure":"inHg"`)
+	assert.Equal(t, float64Ptr(0), data[0].Temperature)
+}
+
+func TestStationsBadUnits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?units=kelvin")
+
+	handler.Stations()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Contains(t, recorder.Body.String(), `"units"`)
+}
+
+func TestPrecipitationOk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	store := NewHistoryStore(24 * time.Hour)
+	store.now = func() time.Time { return now }
+
+	_, _ = store.Add(1, Observation{Time: now.Add(-3 * time.Hour), Precipitation: float64Ptr(2)})
+	_, _ = store.Add(1, Observation{Time: now.Add(-time.Hour), Precipitation: float64Ptr(3.5)})
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+	handler.now = func() time.Time { return now }
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Params = gin.Params{{Key: "code", Value: "1"}}
+
+	var normals [12]float64
+	normals[time.June-1] = 30
+
+	handler.Precipitation(store, PrecipitationNormals{1: normals})(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"window":"last_24h"`)
+	assert.Contains(t, recorder.Body.String(), `"accumulated":1.5,"normal":1,"percent_of_normal":150,"complete":false`)
+}
+
+func TestPrecipitationNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := NewHistoryStore(24 * time.Hour)
+	_, _ = store.Add(1, Observation{Time: time.Now().Add(-time.Hour), Temperature: float64Ptr(10)})
+
+	handler := NewHandler(env.NewTestEnv(), MockService{})
+
+	for _, code := range []string{"1", "2"} {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+
+		ctx.Request = &http.Request{}
+		ctx.Params = gin.Params{{Key: "code", Value: code}}
+
+		handler.Precipitation(store, nil)(ctx)
+
+		assert.Equal(t, recorder.Code, http.StatusNotFound, code)
+	}
+}
+
+func TestSummaryOk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	data := []*ClimateStation{
+		{Code: 1, Name: "test1", Operational: true, Temperature: float64Ptr(10), Location: &Location{Region: "A"}},
+	}
+
+	handler := NewHandler(env.NewTestEnv(), MockService{stations: data})
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	ctx.Request = &http.Request{}
+	ctx.Request.URL, _ = url.Parse("?units=imperial")
+
+	handler.Summary()(ctx)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), `"region":"A"`)
+	assert.Contains(t, recorder.Body.String(), `"min_temperature":{"code":1,"name":"test1","value":50}`)
+	assert.Contains(t, recorder.Body.String(), `"system":"imperial"`)
+}
@@ -0,0 +1,322 @@
+package weather
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Observation is a single reading of the current conditions of a station. Values not reported by the station are nil.
+// Precipitation is the running total of the day at the time of the reading.
+type Observation struct {
+	Time          time.Time `json:"time"`
+	Temperature   *float64  `json:"temperature"`
+	Humidity      *float64  `json:"humidity"`
+	PressureHPA   *float64  `json:"pressure_hpa"`
+	Precipitation *float64  `json:"precipitation,omitempty"`
+}
+
+type Aggregate struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+
+	samples int
+}
+
+// AggregatedObservation summarizes the observations of a station over a period starting at Time. An aggregate is
+// nil if no observation in the period reported its value.
+type AggregatedObservation struct {
+	Time        time.Time  `json:"time"`
+	Samples     int        `json:"samples"`
+	Temperature *Aggregate `json:"temperature"`
+	Humidity    *Aggregate `json:"humidity"`
+	PressureHPA *Aggregate `json:"pressure_hpa"`
+}
+
+// storedObservation is the on-disk representation of an Observation.
+type storedObservation struct {
+	Code int `json:"code"`
+	Observation
+}
+
+// defaultCompactAfter is the amount of expired observations left in the file of a HistoryStore that triggers its
+// compaction, a few days of readings of every station.
+const defaultCompactAfter = 20000
+
+// HistoryStore is a time-series store of station observations. Observations older than the retention are dropped.
+// When backed by a file every observation is appended to it, and the file is compacted when opened and by Compact.
+type HistoryStore struct {
+	mu        sync.RWMutex
+	series    map[int][]Observation
+	retention time.Duration
+	now       func() time.Time
+
+	path string
+	file *os.File
+	// expired counts the observations dropped from memory that are still in the file
+	expired      int
+	compactAfter int
+}
+
+func NewHistoryStore(retention time.Duration) *HistoryStore {
+	return &HistoryStore{
+		series:       make(map[int][]Observation),
+		retention:    retention,
+		now:          time.Now,
+		compactAfter: defaultCompactAfter,
+	}
+}
+
+// OpenHistoryStore loads the observations stored at path, creating the file if it doesn't exist.
+func OpenHistoryStore(path string, retention time.Duration) (*HistoryStore, error) {
+	store := NewHistoryStore(retention)
+
+	err := store.load(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load history")
+	}
+
+	err = store.compact(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compact history")
+	}
+
+	store.path = path
+	store.expired = 0
+
+	store.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open history")
+	}
+
+	return store, nil
+}
+
+// Compact rewrites the file once enough expired observations accumulated in it, so it doesn't grow without bound.
+// It does nothing for stores not backed by a file.
+func (s *HistoryStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil || s.expired < s.compactAfter {
+		return nil
+	}
+
+	err := s.compact(s.path)
+	if err != nil {
+		return errors.Wrap(err, "unable to compact history")
+	}
+
+	s.expired = 0
+
+	// The compacted file replaced the one being appended to
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrap(err, "unable to reopen history")
+	}
+
+	_ = s.file.Close()
+	s.file = file
+
+	return nil
+}
+
+// Add records an observation of a station. Observations for an already recorded time are ignored, and reported
+// by returning false.
+func (s *HistoryStore) Add(code int, obs Observation) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.insert(code, obs) {
+		return false, nil
+	}
+
+	if s.file == nil {
+		return true, nil
+	}
+
+	line, err := json.Marshal(storedObservation{Code: code, Observation: obs})
+	if err != nil {
+		return true, err
+	}
+
+	_, err = s.file.Write(append(line, '\n'))
+	return true, err
+}
+
+// Observations returns the observations of a station in the [from, to) range, oldest first.
+func (s *HistoryStore) Observations(code int, from, to time.Time) []Observation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	series := s.series[code]
+	start := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(from) })
+	end := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(to) })
+
+	if start >= end {
+		return nil
+	}
+
+	result := make([]Observation, end-start)
+	copy(result, series[start:end])
+
+	return result
+}
+
+func (s *HistoryStore) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	return s.file.Close()
+}
+
+// insert adds obs to the series of the station keeping it sorted, and drops the expired observations.
+func (s *HistoryStore) insert(code int, obs Observation) bool {
+	cutoff := s.now().Add(-s.retention)
+	if obs.Time.Before(cutoff) {
+		return false
+	}
+
+	series := s.series[code]
+
+	i := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(obs.Time) })
+	if i < len(series) && series[i].Time.Equal(obs.Time) {
+		return false
+	}
+
+	series = append(series, Observation{})
+	copy(series[i+1:], series[i:])
+	series[i] = obs
+
+	expired := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(cutoff) })
+	s.series[code] = series[expired:]
+	s.expired += expired
+
+	return true
+}
+
+func (s *HistoryStore) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var stored storedObservation
+		if json.Unmarshal(scanner.Bytes(), &stored) != nil {
+			// Skip lines truncated by a crash mid-write
+			continue
+		}
+
+		s.insert(stored.Code, stored.Observation)
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the file at path with only the observations currently held.
+func (s *HistoryStore) compact(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for code, series := range s.series {
+		for _, obs := range series {
+			err = encoder.Encode(storedObservation{Code: code, Observation: obs})
+			if err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// aggregateObservations groups observations into periods of the given resolution. Periods follow the wall clock of
+// Santiago, so daily ones start at local midnight, and the hour repeated when DST ends falls in a single period.
+func aggregateObservations(observations []Observation, resolution time.Duration) []*AggregatedObservation {
+	var result []*AggregatedObservation
+	var current *AggregatedObservation
+
+	for _, obs := range observations {
+		bucket := truncateLocal(obs.Time, resolution)
+		if current == nil || !current.Time.Equal(bucket) {
+			current = &AggregatedObservation{Time: bucket}
+			result = append(result, current)
+		}
+
+		current.Samples++
+		current.Temperature = current.Temperature.add(obs.Temperature)
+		current.Humidity = current.Humidity.add(obs.Humidity)
+		current.PressureHPA = current.PressureHPA.add(obs.PressureHPA)
+	}
+
+	return result
+}
+
+// truncateLocal rounds t down to a multiple of d of the wall clock of Santiago, keeping the location of t.
+func truncateLocal(t time.Time, d time.Duration) time.Time {
+	local := t.In(santiago)
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(),
+		local.Nanosecond(), time.UTC).Truncate(d)
+
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(),
+		santiago).In(t.Location())
+}
+
+// add accounts for a value in the aggregate, creating it if it's nil. Missing values are ignored.
+func (a *Aggregate) add(value *float64) *Aggregate {
+	if value == nil {
+		return a
+	}
+
+	if a == nil {
+		return &Aggregate{Min: *value, Max: *value, Avg: *value, samples: 1}
+	}
+
+	a.samples++
+
+	if *value < a.Min {
+		a.Min = *value
+	}
+
+	if *value > a.Max {
+		a.Max = *value
+	}
+
+	a.Avg += (*value - a.Avg) / float64(a.samples)
+
+	return a
+}
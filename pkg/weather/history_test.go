@@ -0,0 +1,205 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryStoreAdd(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	store := NewHistoryStore(24 * time.Hour)
+	store.now = func() time.Time { return now }
+
+	added, err := store.Add(1, Observation{Time: now.Add(-time.Hour), Temperature: float64Ptr(10)})
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	added, _ = store.Add(1, Observation{Time: now.Add(-3 * time.Hour), Temperature: float64Ptr(8)})
+	assert.True(t, added)
+
+	added, _ = store.Add(1, Observation{Time: now.Add(-time.Hour), Temperature: float64Ptr(11)})
+	assert.False(t, added, "duplicated time")
+
+	added, _ = store.Add(1, Observation{Time: now.Add(-48 * time.Hour), Temperature: float64Ptr(5)})
+	assert.False(t, added, "expired")
+
+	observations := store.Observations(1, now.Add(-24*time.Hour), now)
+	assert.Len(t, observations, 2)
+	assert.Equal(t, float64Ptr(8), observations[0].Temperature)
+	assert.Equal(t, float64Ptr(10), observations[1].Temperature)
+
+	assert.Empty(t, store.Observations(1, now.Add(-2*time.Hour), now.Add(-90*time.Minute)))
+	assert.Empty(t, store.Observations(2, now.Add(-24*time.Hour), now))
+}
+
+func TestHistoryStoreRetention(t *testing.T) {
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	store := NewHistoryStore(2 * time.Hour)
+	store.now = func() time.Time { return now }
+
+	_, _ = store.Add(1, Observation{Time: now.Add(-time.Hour)})
+
+	now = now.Add(3 * time.Hour)
+	_, _ = store.Add(1, Observation{Time: now})
+
+	assert.Len(t, store.Observations(1, time.Time{}, now.Add(time.Hour)), 1)
+}
+
+func TestHistoryStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Now().Truncate(time.Second)
+
+	store, err := OpenHistoryStore(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unable to open store: %v", err)
+	}
+
+	_, _ = store.Add(1, Observation{Time: now.Add(-time.Hour), Temperature: float64Ptr(10), Humidity: float64Ptr(20), PressureHPA: float64Ptr(1010)})
+	_, _ = store.Add(2, Observation{Time: now.Add(-time.Hour), Temperature: float64Ptr(15)})
+	assert.NoError(t, store.Close())
+
+	// A partially written line must not prevent loading the rest
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	_, _ = f.WriteString(`{"code":3,"ti`)
+	_ = f.Close()
+
+	store, err = OpenHistoryStore(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unable to reopen store: %v", err)
+	}
+
+	defer store.Close()
+
+	observations := store.Observations(1, now.Add(-2*time.Hour), now)
+	if assert.Len(t, observations, 1) {
+		assert.True(t, observations[0].Time.Equal(now.Add(-time.Hour)))
+		assert.Equal(t, float64Ptr(1010), observations[0].PressureHPA)
+	}
+
+	assert.Len(t, store.Observations(2, now.Add(-2*time.Hour), now), 1)
+}
+
+func TestHistoryStoreCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Date(2022, 10, 29, 12, 0, 0, 0, time.UTC)
+
+	store, err := OpenHistoryStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("unable to open store: %v", err)
+	}
+
+	defer store.Close()
+
+	store.now = func() time.Time { return now }
+	store.compactAfter = 4
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(10 * time.Minute)
+		_, _ = store.Add(1, Observation{Time: now, Temperature: float64Ptr(10)})
+	}
+
+	// Only 3 observations expired so far
+	assert.NoError(t, store.Compact())
+	assert.Equal(t, 10, countLines(t, path))
+
+	now = now.Add(10 * time.Minute)
+	_, _ = store.Add(1, Observation{Time: now, Temperature: float64Ptr(10)})
+	assert.NoError(t, store.Compact())
+	assert.Equal(t, 7, countLines(t, path))
+
+	// Later observations are appended to the compacted file
+	now = now.Add(10 * time.Minute)
+	_, _ = store.Add(1, Observation{Time: now, Temperature: float64Ptr(10)})
+	assert.Equal(t, 8, countLines(t, path))
+}
+
+func TestAggregateObservations(t *testing.T) {
+	start := time.Date(2022, 10, 29, 10, 0, 0, 0, time.UTC)
+
+	observations := []Observation{
+		{Time: start, Temperature: float64Ptr(10), Humidity: float64Ptr(50), PressureHPA: float64Ptr(1000)},
+		{Time: start.Add(20 * time.Minute), Temperature: float64Ptr(12), Humidity: float64Ptr(40), PressureHPA: float64Ptr(1002)},
+		{Time: start.Add(40 * time.Minute), Temperature: float64Ptr(14), Humidity: float64Ptr(30), PressureHPA: float64Ptr(1004)},
+		{Time: start.Add(70 * time.Minute), Temperature: float64Ptr(9), Humidity: float64Ptr(60), PressureHPA: float64Ptr(999)},
+		{Time: start.Add(80 * time.Minute), Humidity: float64Ptr(50)},
+	}
+
+	aggregated := aggregateObservations(observations, time.Hour)
+	if !assert.Len(t, aggregated, 2) {
+		return
+	}
+
+	assert.Equal(t, start, aggregated[0].Time)
+	assert.Equal(t, 3, aggregated[0].Samples)
+	assert.Equal(t, &Aggregate{Min: 10, Max: 14, Avg: 12, samples: 3}, aggregated[0].Temperature)
+	assert.Equal(t, &Aggregate{Min: 30, Max: 50, Avg: 40, samples: 3}, aggregated[0].Humidity)
+	assert.Equal(t, &Aggregate{Min: 1000, Max: 1004, Avg: 1002, samples: 3}, aggregated[0].PressureHPA)
+
+	assert.Equal(t, start.Add(time.Hour), aggregated[1].Time)
+	assert.Equal(t, 2, aggregated[1].Samples)
+	assert.Equal(t, &Aggregate{Min: 9, Max: 9, Avg: 9, samples: 1}, aggregated[1].Temperature)
+	assert.Equal(t, &Aggregate{Min: 50, Max: 60, Avg: 55, samples: 2}, aggregated[1].Humidity)
+	assert.Nil(t, aggregateObservations([]Observation{{Time: start}}, time.Hour)[0].Temperature)
+}
+
+func TestAggregateObservationsDaily(t *testing.T) {
+	observations := []Observation{
+		// 23:00 and 01:00 in Santiago, which is at UTC-3
+		{Time: time.Date(2022, 10, 29, 2, 0, 0, 0, time.UTC), Temperature: float64Ptr(10)},
+		{Time: time.Date(2022, 10, 29, 4, 0, 0, 0, time.UTC), Temperature: float64Ptr(12)},
+		{Time: time.Date(2022, 10, 29, 23, 0, 0, 0, time.UTC), Temperature: float64Ptr(14)},
+	}
+
+	aggregated := aggregateObservations(observations, 24*time.Hour)
+	if !assert.Len(t, aggregated, 2) {
+		return
+	}
+
+	assert.Equal(t, time.Date(2022, 10, 28, 3, 0, 0, 0, time.UTC), aggregated[0].Time)
+	assert.Equal(t, 1, aggregated[0].Samples)
+	assert.Equal(t, time.Date(2022, 10, 29, 3, 0, 0, 0, time.UTC), aggregated[1].Time)
+	assert.Equal(t, 2, aggregated[1].Samples)
+}
+
+func TestCollectorRecords(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	earlier := now.Add(-time.Hour)
+
+	service := MockService{stations: []*ClimateStation{
+		{Code: 1, Operational: true, LastReport: &now, Temperature: float64Ptr(10)},
+		{Code: 2, Operational: false, LastReport: &earlier},
+		{Code: 3, Operational: true},
+	}}
+
+	store := NewHistoryStore(24 * time.Hour)
+	collector := NewCollector(env.NewTestEnv(), service, store)
+
+	stations, err := collector.GetClimateStations(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, stations, 3)
+
+	_, _ = collector.GetClimateStations(context.Background())
+
+	assert.Len(t, collector.Observations(1, earlier, now.Add(time.Minute)), 1)
+	assert.Empty(t, collector.Observations(2, earlier, now.Add(time.Minute)))
+	assert.Empty(t, collector.Observations(3, time.Time{}, now.Add(time.Minute)))
+}
+
+func countLines(t *testing.T, path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read history: %v", err)
+	}
+
+	return bytes.Count(content, []byte("\n"))
+}
@@ -0,0 +1,128 @@
+package weather
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// earthRadiusKM is the mean radius of the Earth, used for great-circle distances
+const earthRadiusKM = 6371.0
+
+//go:embed stations.csv
+var stationsCSV []byte
+
+// stationLocations maps station codes to their location. Coordinates are approximate to the town the station is in.
+var stationLocations = mustParseLocations(stationsCSV)
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Region    string  `json:"region"`
+	Commune   string  `json:"commune"`
+}
+
+type StationDistance struct {
+	*ClimateStation
+	DistanceKM float64 `json:"distance_km"`
+}
+
+func parseLocations(data []byte) (map[int]*Location, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make(map[int]*Location)
+	for i, record := range records {
+		if i == 0 {
+			// Header
+			continue
+		}
+
+		if len(record) != 6 {
+			return nil, fmt.Errorf("line %d: expected 6 fields, got %d", i+1, len(record))
+		}
+
+		code, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid code '%s'", i+1, record[0])
+		}
+
+		var coords [3]float64
+		for j := range coords {
+			coords[j], err = strconv.ParseFloat(record[j+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid number '%s'", i+1, record[j+1])
+			}
+		}
+
+		locations[code] = &Location{
+			Latitude:  coords[0],
+			Longitude: coords[1],
+			Altitude:  coords[2],
+			Region:    record[4],
+			Commune:   record[5],
+		}
+	}
+
+	return locations, nil
+}
+
+func mustParseLocations(data []byte) map[int]*Location {
+	locations, err := parseLocations(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid station locations: %v", err))
+	}
+
+	return locations
+}
+
+// locateStations sets the location of every station with known metadata.
+func locateStations(stations []*ClimateStation) {
+	for _, station := range stations {
+		station.Location = stationLocations[station.Code]
+	}
+}
+
+// nearestStations returns up to limit located stations, closest to the given coordinates first.
+func nearestStations(stations []*ClimateStation, lat, lon float64, limit int) []*StationDistance {
+	var results []*StationDistance
+	for _, station := range stations {
+		if station.Location == nil {
+			continue
+		}
+
+		results = append(results, &StationDistance{
+			ClimateStation: station,
+			DistanceKM:     distanceKM(lat, lon, station.Location.Latitude, station.Location.Longitude),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].DistanceKM < results[j].DistanceKM
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// distanceKM is the great-circle distance between two coordinates, using the haversine formula.
+func distanceKM(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Pow(math.Sin(dLon/2), 2)
+
+	return 2 * earthRadiusKM * math.Asin(math.Sqrt(a))
+}
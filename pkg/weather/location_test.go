@@ -0,0 +1,58 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/ccuetoh/libreapi/internal/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationLocationsCoverStations(t *testing.T) {
+	var stations []*ClimateStation
+	err := test.LoadJSON("stations_ok", &stations)
+	if err != nil {
+		t.Fatalf("unable to load test case json: %v", err)
+	}
+
+	locateStations(stations)
+
+	for _, station := range stations {
+		assert.NotNil(t, station.Location, "station %d has no location", station.Code)
+	}
+}
+
+func TestParseLocationsInvalid(t *testing.T) {
+	_, err := parseLocations([]byte("code,latitude,longitude,altitude,region,commune\n1,a,2,3,Region,Commune\n"))
+	assert.Error(t, err)
+
+	_, err = parseLocations([]byte("code,latitude,longitude,altitude,region,commune\n1,2,3\n"))
+	assert.Error(t, err)
+}
+
+func TestDistanceKM(t *testing.T) {
+	assert.InDelta(t, 0, distanceKM(-33.45, -70.67, -33.45, -70.67), 0.001)
+	assert.InDelta(t, 98.9, distanceKM(-33.45, -70.67, -33.05, -71.62), 0.1)
+	assert.InDelta(t, 3852.9, distanceKM(-18.355, -70.339, -53.003, -70.855), 0.1)
+}
+
+func TestNearestStations(t *testing.T) {
+	stations := []*ClimateStation{
+		{Code: 520006, Name: "Carlos Ibañez, Punta Arenas Ap."},
+		{Code: 330020, Name: "Quinta Normal, Santiago"},
+		{Code: 330021, Name: "Pudahuel Santiago"},
+		{Code: 1, Name: "Unknown"},
+	}
+
+	locateStations(stations)
+
+	got := nearestStations(stations, -33.44, -70.68, 2)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 330020, got[0].Code)
+	assert.Equal(t, 330021, got[1].Code)
+	assert.Less(t, got[0].DistanceKM, got[1].DistanceKM)
+
+	got = nearestStations(stations, -33.44, -70.68, 10)
+	assert.Len(t, got, 3)
+	assert.Equal(t, 520006, got[2].Code)
+}
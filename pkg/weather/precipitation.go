@@ -0,0 +1,141 @@
+package weather
+
+import (
+	"time"
+)
+
+// PrecipitationNormals holds the normal monthly rainfall in mm of each station, from January to December.
+type PrecipitationNormals map[int][12]float64
+
+// PrecipitationWindow is the rainfall accumulated by a station over a period. Normal is the rainfall expected for the
+// period, prorated from the monthly normals. Complete is false when the recorded history doesn't cover the whole
+// period, in which case the accumulation only counts the covered part.
+type PrecipitationWindow struct {
+	Window          string    `json:"window"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Accumulated     float64   `json:"accumulated"`
+	Normal          *float64  `json:"normal"`
+	PercentOfNormal *float64  `json:"percent_of_normal"`
+	Complete        bool      `json:"complete"`
+}
+
+type precipitationPeriod struct {
+	name string
+	from time.Time
+}
+
+// precipitationPeriods returns the start of each reported window ending at now. Months and years start at midnight
+// in Santiago.
+func precipitationPeriods(now time.Time) []precipitationPeriod {
+	local := now.In(santiago)
+
+	return []precipitationPeriod{
+		{"last_24h", now.Add(-24 * time.Hour)},
+		{"last_7d", now.Add(-7 * 24 * time.Hour)},
+		{"month_to_date", time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, santiago)},
+		{"year_to_date", time.Date(local.Year(), time.January, 1, 0, 0, 0, 0, santiago)},
+	}
+}
+
+func precipitationSummary(observations []Observation, normals *[12]float64, now time.Time) []*PrecipitationWindow {
+	var windows []*PrecipitationWindow
+	for _, period := range precipitationPeriods(now) {
+		accumulated, complete := accumulatePrecipitation(observations, period.from, now)
+
+		result := &PrecipitationWindow{
+			Window:      period.name,
+			From:        period.from,
+			To:          now,
+			Accumulated: round(accumulated, 1),
+			Complete:    complete,
+		}
+
+		if normals != nil {
+			normal := normalPrecipitation(*normals, period.from, now)
+			result.Normal = float64Ptr(round(normal, 1))
+
+			if normal > 0 {
+				result.PercentOfNormal = float64Ptr(round(accumulated/normal*100, 1))
+			}
+		}
+
+		windows = append(windows, result)
+	}
+
+	return windows
+}
+
+// accumulatePrecipitation adds up the rainfall between observations in the (from, to] range. Observations hold the
+// running total of the day, so the rainfall between two observations is their difference, or the whole total when
+// it went down because a new day started. The last observation before from is the baseline of the first one; without
+// it the rainfall before the first observation is unknown and the result isn't complete.
+func accumulatePrecipitation(observations []Observation, from, to time.Time) (float64, bool) {
+	var accumulated float64
+	var previous *float64
+	complete := false
+
+	for _, obs := range observations {
+		if obs.Precipitation == nil || obs.Time.After(to) {
+			continue
+		}
+
+		if !obs.Time.After(from) {
+			previous = obs.Precipitation
+			complete = true
+			continue
+		}
+
+		current := *obs.Precipitation
+		switch {
+		case previous == nil:
+		case current >= *previous:
+			accumulated += current - *previous
+		default:
+			accumulated += current
+		}
+
+		previous = obs.Precipitation
+	}
+
+	return accumulated, complete
+}
+
+// normalPrecipitation prorates the monthly normals over the [from, to) range.
+func normalPrecipitation(normals [12]float64, from, to time.Time) float64 {
+	var normal float64
+
+	local := from.In(santiago)
+	month := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, santiago)
+
+	for month.Before(to) {
+		next := month.AddDate(0, 1, 0)
+
+		start, end := month, next
+		if from.After(start) {
+			start = from
+		}
+
+		if to.Before(end) {
+			end = to
+		}
+
+		if end.After(start) {
+			normal += normals[month.Month()-1] * end.Sub(start).Hours() / next.Sub(month).Hours()
+		}
+
+		month = next
+	}
+
+	return normal
+}
+
+func hasPrecipitation(observations []Observation) bool {
+	for _, obs := range observations {
+		if obs.Precipitation != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,96 @@
+package weather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccumulatePrecipitation(t *testing.T) {
+	start := time.Date(2022, 6, 10, 20, 0, 0, 0, santiago)
+	at := func(hours int, total float64) Observation {
+		return Observation{Time: start.Add(time.Duration(hours) * time.Hour), Precipitation: float64Ptr(total)}
+	}
+
+	observations := []Observation{
+		at(0, 3),
+		at(1, 4.5),
+		at(2, 6),
+		{Time: start.Add(150 * time.Minute)},
+		// A new day started
+		at(4, 1),
+		at(5, 1),
+		at(6, 2.5),
+	}
+
+	accumulated, complete := accumulatePrecipitation(observations, start, start.Add(6*time.Hour))
+	assert.InDelta(t, 5.5, accumulated, 0.001)
+	assert.True(t, complete)
+
+	accumulated, complete = accumulatePrecipitation(observations, start.Add(90*time.Minute), start.Add(5*time.Hour))
+	assert.InDelta(t, 2.5, accumulated, 0.001)
+	assert.True(t, complete)
+
+	// Without a baseline the first observation only sets it
+	accumulated, complete = accumulatePrecipitation(observations, start.Add(-time.Hour), start.Add(6*time.Hour))
+	assert.InDelta(t, 5.5, accumulated, 0.001)
+	assert.False(t, complete)
+}
+
+func TestNormalPrecipitation(t *testing.T) {
+	var normals [12]float64
+	normals[time.May-1] = 31
+	normals[time.June-1] = 60
+
+	// The whole of May
+	from := time.Date(2022, 5, 1, 0, 0, 0, 0, santiago)
+	assert.InDelta(t, 31, normalPrecipitation(normals, from, from.AddDate(0, 1, 0)), 0.001)
+
+	// The last day of May and the first 2 days of June
+	from = time.Date(2022, 5, 31, 0, 0, 0, 0, santiago)
+	assert.InDelta(t, 5, normalPrecipitation(normals, from, from.AddDate(0, 0, 3)), 0.001)
+
+	// Half a day
+	assert.InDelta(t, 0.5, normalPrecipitation(normals, from, from.Add(12*time.Hour)), 0.001)
+}
+
+func TestPrecipitationSummary(t *testing.T) {
+	now := time.Date(2022, 6, 15, 12, 0, 0, 0, santiago)
+
+	observations := []Observation{
+		{Time: time.Date(2022, 5, 31, 12, 0, 0, 0, santiago), Precipitation: float64Ptr(0)},
+		{Time: time.Date(2022, 6, 1, 12, 0, 0, 0, santiago), Precipitation: float64Ptr(20)},
+		{Time: time.Date(2022, 6, 14, 18, 0, 0, 0, santiago), Precipitation: float64Ptr(4)},
+		{Time: time.Date(2022, 6, 15, 9, 0, 0, 0, santiago), Precipitation: float64Ptr(1)},
+	}
+
+	var normals [12]float64
+	normals[time.June-1] = 60
+
+	windows := precipitationSummary(observations, &normals, now)
+	if !assert.Len(t, windows, 4) {
+		return
+	}
+
+	assert.Equal(t, "last_24h", windows[0].Window)
+	// The day restarted twice, after the 20mm of June 1st and the 4mm of June 14th
+	assert.Equal(t, 5.0, windows[0].Accumulated)
+	assert.True(t, windows[0].Complete)
+	assert.Equal(t, float64Ptr(2), windows[0].Normal)
+	assert.Equal(t, float64Ptr(250), windows[0].PercentOfNormal)
+
+	assert.Equal(t, "month_to_date", windows[2].Window)
+	assert.Equal(t, time.Date(2022, 6, 1, 0, 0, 0, 0, santiago), windows[2].From)
+	assert.Equal(t, 25.0, windows[2].Accumulated)
+	assert.True(t, windows[2].Complete)
+	assert.Equal(t, float64Ptr(29), windows[2].Normal)
+
+	assert.Equal(t, "year_to_date", windows[3].Window)
+	assert.Equal(t, 25.0, windows[3].Accumulated)
+	assert.False(t, windows[3].Complete)
+
+	windows = precipitationSummary(observations, nil, now)
+	assert.Nil(t, windows[0].Normal)
+	assert.Nil(t, windows[0].PercentOfNormal)
+}
@@ -0,0 +1,101 @@
+package weather
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// Report times are local to Santiago, so don't depend on the host having the zone database
+	_ "time/tzdata"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var santiago = mustLoadLocation("America/Santiago")
+
+// reportTolerance is how far ahead of the clock a report time may be before it's considered to be from the day
+// before, allowing for clock differences with meteochile.
+const reportTolerance = 15 * time.Minute
+
+var reportDateRegexp = regexp.MustCompile(`Hoy \((\d{2}-\d{2}-\d{4})\)`)
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("unable to load location %s: %v", name, err))
+	}
+
+	return loc
+}
+
+// parseReportDate reads the date the page considers today from its headers. The date is returned at midnight UTC and
+// only its year, month and day are meaningful, as midnight might not exist in Santiago when DST starts.
+func parseReportDate(doc *goquery.Document) (time.Time, error) {
+	header := doc.Find(".table-bordered > tbody:nth-child(1) > tr:nth-child(2)").Text()
+
+	match := reportDateRegexp.FindStringSubmatch(header)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("report date not found")
+	}
+
+	date, err := time.Parse("02-01-2006", match[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid report date %q: %v", match[1], err)
+	}
+
+	return date, nil
+}
+
+// hourOn returns a converter of "HH:MM" hours into times of the given date in Santiago. The time is built from the
+// wall clock, so hours are correct on the days DST starts or ends. Hours not reported convert to nil.
+func hourOn(date time.Time) func(hour string) (*time.Time, error) {
+	return func(hour string) (*time.Time, error) {
+		hour = strings.TrimSpace(hour)
+
+		if notReported(hour) {
+			return nil, nil
+		}
+
+		sections := strings.Split(hour, ":")
+		if len(sections) != 2 {
+			return nil, fmt.Errorf("invalid hour %q", hour)
+		}
+
+		h, err := strconv.Atoi(sections[0])
+		if err != nil || h < 0 || h > 23 {
+			return nil, fmt.Errorf("invalid hour %q", hour)
+		}
+
+		m, err := strconv.Atoi(sections[1])
+		if err != nil || m < 0 || m > 59 {
+			return nil, fmt.Errorf("invalid hour %q", hour)
+		}
+
+		t := time.Date(date.Year(), date.Month(), date.Day(), h, m, 0, 0, santiago)
+
+		// Hours skipped when DST starts don't exist and are normalized to the previous day. Move them forward by the
+		// gap, as read by a clock that wasn't adjusted yet
+		wanted := time.Date(date.Year(), date.Month(), date.Day(), h, m, 0, 0, time.UTC)
+		got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+		t = t.Add(wanted.Sub(got))
+
+		return &t, nil
+	}
+}
+
+// lastReportOn converts the hour of the last report of a station. Reports are stamped on the date of the page, but
+// a report later than now is from the day before, such as a station that last reported at 23:50 read just after
+// midnight.
+func lastReportOn(date time.Time, now time.Time) func(hour string) (*time.Time, error) {
+	today, yesterday := hourOn(date), hourOn(date.AddDate(0, 0, -1))
+
+	return func(hour string) (*time.Time, error) {
+		t, err := today(hour)
+		if err != nil || t == nil || !t.After(now.Add(reportTolerance)) {
+			return t, err
+		}
+
+		return yesterday(hour)
+	}
+}
@@ -0,0 +1,93 @@
+package weather
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHourOn(t *testing.T) {
+	date := time.Date(2022, 10, 29, 0, 0, 0, 0, time.UTC)
+	parse := hourOn(date)
+
+	got, err := parse(" 13:30 ")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 10, 29, 13, 30, 0, 0, santiago), *got)
+
+	got, err = parse(".")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	for _, malformed := range []string{"1330", "13:", ":30", "13:30:00", "25:00", "13:60", "aa:bb", "-1:30"} {
+		got, err = parse(malformed)
+		assert.Error(t, err, malformed)
+		assert.Nil(t, got, malformed)
+	}
+}
+
+func TestHourOnDST(t *testing.T) {
+	// DST started on 2022-09-11, clocks went from 00:00 -04 to 01:00 -03
+	start := time.Date(2022, 9, 11, 0, 0, 0, 0, time.UTC)
+
+	got, err := hourOn(start)("13:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-09-11T13:30:00-03:00", got.Format(time.RFC3339))
+
+	// The skipped hour doesn't exist, but must still land on the right day
+	got, err = hourOn(start)("00:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-09-11T01:30:00-03:00", got.Format(time.RFC3339))
+
+	// DST ended on 2022-04-03, clocks went from 00:00 -03 back to 23:00 -04
+	end := time.Date(2022, 4, 3, 0, 0, 0, 0, time.UTC)
+
+	got, err = hourOn(end)("13:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-04-03T13:30:00-04:00", got.Format(time.RFC3339))
+}
+
+func TestLastReportOn(t *testing.T) {
+	date := time.Date(2022, 10, 30, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 10, 30, 0, 5, 0, 0, santiago)
+
+	parse := lastReportOn(date, now)
+
+	got, err := parse("23:50")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 10, 29, 23, 50, 0, 0, santiago), *got)
+
+	got, err = parse("00:00")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 10, 30, 0, 0, 0, 0, santiago), *got)
+
+	// Small clock differences with meteochile are tolerated
+	got, err = parse("00:15")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 10, 30, 0, 15, 0, 0, santiago), *got)
+
+	_, err = parse("2350")
+	assert.Error(t, err)
+}
+
+func TestParseReportDate(t *testing.T) {
+	page := `<table class="table-bordered"><tbody><tr><td>Código</td></tr>
+		<tr><td>Hora</td><td>Hoy (29-10-2022)</td><td>Ayer (28-10-2022)</td></tr></tbody></table>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("unable to parse page: %v", err)
+	}
+
+	date, err := parseReportDate(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 10, 29, 0, 0, 0, 0, time.UTC), date)
+
+	page = strings.Replace(page, "29-10-2022", "31-02-2022", 1)
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(page))
+
+	_, err = parseReportDate(doc)
+	assert.Error(t, err)
+}
@@ -2,6 +2,7 @@ package weather
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,25 +16,32 @@ import (
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
 	"github.com/PuerkitoBio/goquery"
 	"github.com/sahilm/fuzzy"
 )
 
+// ClimateStation holds the latest data reported by a station. Values the station didn't report are null, and values
+// that couldn't be parsed are also null and have the reason in Anomalies, keyed by the JSON path of the field.
 type ClimateStation struct {
-	Code        int            `json:"code"`
-	Name        string         `json:"name"`
-	Operational bool           `json:"operational"`
-	LastReport  *time.Time     `json:"last_report,omitempty"`
-	Temperature float64        `json:"temperature,omitempty"`
-	Humidity    float64        `json:"humidity,omitempty"`
-	PressureHPA float64        `json:"pressure_hpa,omitempty"`
-	Today       *ClimateReport `json:"today,omitempty"`
-	Yesterday   *ClimateReport `json:"yesterday,omitempty"`
+	Code        int               `json:"code"`
+	Name        string            `json:"name"`
+	Operational bool              `json:"operational"`
+	LastReport  *time.Time        `json:"last_report,omitempty"`
+	Temperature *float64          `json:"temperature"`
+	Humidity    *float64          `json:"humidity"`
+	PressureHPA *float64          `json:"pressure_hpa"`
+	Today       *ClimateReport    `json:"today,omitempty"`
+	Yesterday   *ClimateReport    `json:"yesterday,omitempty"`
+	Location    *Location         `json:"location,omitempty"`
+	Derived     *DerivedMetrics   `json:"derived,omitempty"`
+	Anomalies   map[string]string `json:"anomalies,omitempty"`
 }
 
 type Precipitations struct {
-	Sum float64 `json:"sum"`
-	EMA float64 `json:"ema"`
+	Sum *float64 `json:"sum"`
+	EMA *float64 `json:"ema"`
 }
 
 type ClimateReport struct {
@@ -43,24 +51,61 @@ type ClimateReport struct {
 }
 
 type Measurement struct {
-	Time        time.Time `json:"time"`
-	Temperature float64   `json:"temperature"`
+	Time        *time.Time `json:"time"`
+	Temperature *float64   `json:"temperature"`
+}
+
+// fieldAnomalies maps the JSON path of a field to the reason it couldn't be parsed.
+type fieldAnomalies map[string]string
+
+// parse converts the raw value of a field, recording an anomaly if it's malformed.
+func (a fieldAnomalies) parse(field string, raw string, convert func(string) (*float64, error)) *float64 {
+	value, err := convert(raw)
+	if err != nil {
+		a.record(field, raw)
+		return nil
+	}
+
+	return value
 }
 
+// parseTime converts the raw time of a field, recording an anomaly if it's malformed.
+func (a fieldAnomalies) parseTime(field string, raw string, convert func(string) (*time.Time, error)) *time.Time {
+	value, err := convert(raw)
+	if err != nil {
+		a.record(field, raw)
+		return nil
+	}
+
+	return value
+}
+
+func (a fieldAnomalies) record(field string, raw string) {
+	a[field] = fmt.Sprintf("unparsable value %q", strings.TrimSpace(raw))
+}
+
+const SourceEMAs = "meteochile_emas"
+
 type DefaultService struct {
-	client *http.Client
+	client *upstream.Client
+	now    func() time.Time
 }
 
-func NewDefaultService() *DefaultService {
+func NewDefaultService(opts ...upstream.Option) *DefaultService {
+	client := upstream.NewClient(3*time.Second, opts...)
+	client.Register(SourceEMAs)
+
 	return &DefaultService{
-		client: &http.Client{
-			Timeout: 3 * time.Second,
-		},
+		client: client,
+		now:    time.Now,
 	}
 }
 
-func (s *DefaultService) GetClimateStations() ([]*ClimateStation, error) {
-	res, err := s.client.Get("https://climatologia.meteochile.gob.cl/application/diario/climatDiarioRecienteEmas/")
+func (s *DefaultService) GetClimateStations(ctx context.Context) (stations []*ClimateStation, err error) {
+	fetch := s.client.Track(SourceEMAs)
+	defer fetch.Done(&err)
+
+	res, err := s.client.GetContext(ctx, "https://climatologia.meteochile.gob.cl/application/diario/climatDiarioRecienteEmas/")
 	if err != nil {
 		return nil, err
 	}
@@ -71,20 +116,53 @@ func (s *DefaultService) GetClimateStations() ([]*ClimateStation, error) {
 		return nil, fmt.Errorf("status code error: %s", res.Status)
 	}
 
-	stations, err := parseClimateHTML(res.Body)
+	fetch.Fetched()
+
+	stations, err = parseClimateHTML(res.Body, s.now())
 	if err != nil {
 		return nil, err
 	}
 
+	locateStations(stations)
+	deriveMetrics(stations)
+
 	return stations, nil
 }
 
-func parseClimateHTML(r io.ReadCloser) (stations []*ClimateStation, err error) {
+var climateFingerprint = upstream.Fingerprint{
+	Source: SourceEMAs,
+	Expectations: []upstream.Expectation{
+		{Selector: ".table-bordered > tbody:nth-child(1) > tr:nth-child(1)", Text: "Estación"},
+		{Selector: ".table-bordered > tbody:nth-child(1) > tr:nth-child(1)", Text: "Temperaturas Extremas"},
+		{Selector: ".table-bordered > tbody:nth-child(1) > tr:nth-child(1)", Text: "Precipitación"},
+		{Selector: ".table-bordered > tbody:nth-child(1) > tr:nth-child(2)", Text: "Hora"},
+		{Selector: ".table-bordered > tbody:nth-child(1) > tr:nth-child(2)", Text: "Hoy ("},
+		// Three header rows and at least one station
+		{Selector: ".table-bordered > tbody:nth-child(1) > tr", Min: 4},
+	},
+}
+
+// parseClimateHTML parses the stations of the page. Report times are dated with the page's own date, now is only used
+// to tell apart reports from the day before.
+func parseClimateHTML(r io.ReadCloser, now time.Time) (stations []*ClimateStation, err error) {
 	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
 		return nil, err
 	}
 
+	err = climateFingerprint.Validate(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := parseReportDate(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	today, yesterday := hourOn(date), hourOn(date.AddDate(0, 0, -1))
+	lastReport := lastReportOn(date, now)
+
 	doc.Find(".table-bordered > tbody:nth-child(1) > tr").Each(func(i int, row *goquery.Selection) {
 		if i < 3 {
 			// Headers
@@ -102,45 +180,53 @@ func parseClimateHTML(r io.ReadCloser) (stations []*ClimateStation, err error) {
 			return
 		}
 
+		anomalies := fieldAnomalies{}
+		cell := func(n int) string {
+			return row.Find(fmt.Sprintf("td:nth-child(%d)", n)).Text()
+		}
+
 		station := &ClimateStation{
 			Operational: true,
-			Name:        cleanName(row.Find("td:nth-child(3)").Text()),
-			Temperature: tempToFloat64(row.Find("td:nth-child(5)").Text()),
-			Humidity:    humidityToFloat64(row.Find("td:nth-child(6)").Text()),
-			PressureHPA: pressureToFloat64(row.Find("td:nth-child(7)").Text()),
+			Name:        cleanName(cell(3)),
+			Temperature: anomalies.parse("temperature", cell(5), tempToFloat64),
+			Humidity:    anomalies.parse("humidity", cell(6), humidityToFloat64),
+			PressureHPA: anomalies.parse("pressure_hpa", cell(7), pressureToFloat64),
 			Today: &ClimateReport{
 				Maximum: Measurement{
-					Time:        todayHourToTime(row.Find("td:nth-child(9)").Text()),
-					Temperature: tempToFloat64(row.Find("td:nth-child(8)").Text()),
+					Time:        anomalies.parseTime("today.maximum.time", cell(9), today),
+					Temperature: anomalies.parse("today.maximum.temperature", cell(8), tempToFloat64),
 				},
 				Minimum: Measurement{
-					Time:        todayHourToTime(row.Find("td:nth-child(11)").Text()),
-					Temperature: tempToFloat64(row.Find("td:nth-child(10)").Text()),
+					Time:        anomalies.parseTime("today.minimum.time", cell(11), today),
+					Temperature: anomalies.parse("today.minimum.temperature", cell(10), tempToFloat64),
 				},
 				Precipitations: Precipitations{
-					Sum: precipitationsToFloat64(row.Find("td:nth-child(16)").Text()),
-					EMA: precipitationsToFloat64(row.Find("td:nth-child(17)").Text()),
+					Sum: anomalies.parse("today.precipitations.sum", cell(16), precipitationsToFloat64),
+					EMA: anomalies.parse("today.precipitations.ema", cell(17), precipitationsToFloat64),
 				},
 			},
 			Yesterday: &ClimateReport{
 				Maximum: Measurement{
-					Time:        yesterdayHourToTime(row.Find("td:nth-child(13)").Text()),
-					Temperature: tempToFloat64(row.Find("td:nth-child(12)").Text()),
+					Time:        anomalies.parseTime("yesterday.maximum.time", cell(13), yesterday),
+					Temperature: anomalies.parse("yesterday.maximum.temperature", cell(12), tempToFloat64),
 				},
 				Minimum: Measurement{
-					Time:        yesterdayHourToTime(row.Find("td:nth-child(15)").Text()),
-					Temperature: tempToFloat64(row.Find("td:nth-child(14)").Text()),
+					Time:        anomalies.parseTime("yesterday.minimum.time", cell(15), yesterday),
+					Temperature: anomalies.parse("yesterday.minimum.temperature", cell(14), tempToFloat64),
 				},
 				Precipitations: Precipitations{
-					Sum: precipitationsToFloat64(row.Find("td:nth-child(18)").Text()),
-					EMA: precipitationsToFloat64(row.Find("td:nth-child(19)").Text()),
+					Sum: anomalies.parse("yesterday.precipitations.sum", cell(18), precipitationsToFloat64),
+					EMA: anomalies.parse("yesterday.precipitations.ema", cell(19), precipitationsToFloat64),
 				},
 			},
 		}
 
-		lastReport := todayHourToTime(row.Find("td:nth-child(4)").Text())
-		station.LastReport = &lastReport
-		station.Code, _ = strconv.Atoi(strings.TrimSpace(row.Find("td:nth-child(2)").Text()))
+		station.LastReport = anomalies.parseTime("last_report", cell(4), lastReport)
+		station.Code, _ = strconv.Atoi(strings.TrimSpace(cell(2)))
+
+		if len(anomalies) != 0 {
+			station.Anomalies = anomalies
+		}
 
 		stations = append(stations, station)
 	})
@@ -148,73 +234,77 @@ func parseClimateHTML(r io.ReadCloser) (stations []*ClimateStation, err error) {
 	return stations, nil
 }
 
-func todayHourToTime(hour string) time.Time {
-	hour = strings.TrimSpace(hour)
-
-	if hour == "." || hour == "" {
-		return time.Time{}
-	}
-
-	loc, _ := time.LoadLocation("America/Santiago")
-
-	now := time.Now()
-	t := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
-
-	sections := strings.Split(hour, ":")
-	h, _ := strconv.Atoi(sections[0])
-	m, _ := strconv.Atoi(sections[1])
-
-	return t.Add((time.Duration(h) * time.Hour) + (time.Duration(m) * time.Minute))
-}
-
-func yesterdayHourToTime(hour string) time.Time {
-	today := todayHourToTime(hour)
-	return today.AddDate(0, 0, -1)
+// notReported reports whether a value is the placeholder used by the page for readings the station didn't send.
+func notReported(value string) bool {
+	return value == "." || value == ""
 }
 
-func tempToFloat64(temp string) float64 {
+func tempToFloat64(temp string) (*float64, error) {
 	temp = strings.TrimSpace(temp)
 
-	if temp == "." {
-		return 0
+	if notReported(temp) {
+		return nil, nil
+	}
+
+	res, err := strconv.ParseFloat(temp, 64)
+	if err != nil {
+		return nil, err
 	}
 
-	res, _ := strconv.ParseFloat(temp, 64)
-	return res
+	return &res, nil
 }
 
-func humidityToFloat64(hum string) float64 {
+func humidityToFloat64(hum string) (*float64, error) {
 	hum = strings.TrimSpace(hum)
 
-	if hum == "." {
-		return 0
+	if notReported(hum) {
+		return nil, nil
+	}
+
+	res, err := strconv.ParseFloat(hum, 64)
+	if err != nil {
+		return nil, err
 	}
 
-	res, _ := strconv.ParseFloat(hum, 64)
-	return res / 100
+	res /= 100
+	return &res, nil
 }
 
-func pressureToFloat64(p string) float64 {
+func pressureToFloat64(p string) (*float64, error) {
 	p = strings.TrimSpace(p)
 
-	if p == "." {
-		return 0
+	if notReported(p) {
+		return nil, nil
 	}
 
 	p = strings.ReplaceAll(p, ",", "")
-	res, _ := strconv.ParseFloat(p, 64)
-	return res
+	res, err := strconv.ParseFloat(p, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
 }
 
-func precipitationsToFloat64(pre string) float64 {
+func precipitationsToFloat64(pre string) (*float64, error) {
 	pre = strings.TrimSpace(pre)
 
-	if pre == "s/p" || pre == "." {
-		return 0
+	if notReported(pre) {
+		return nil, nil
+	}
+
+	// "Sin precipitaciones", no rain was recorded
+	if pre == "s/p" {
+		res := 0.0
+		return &res, nil
+	}
+
+	res, err := strconv.ParseFloat(pre, 64)
+	if err != nil {
+		return nil, err
 	}
 
-	res, _ := strconv.ParseFloat(pre, 64)
-	return res
+	return &res, nil
 }
 
 func removeTilde(text string) string {
@@ -1,11 +1,14 @@
 package weather
 
 import (
+	"context"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/ccuetoh/libreapi/internal/test"
+	"github.com/ccuetoh/libreapi/pkg/upstream"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -14,11 +17,14 @@ func TestGetStations(t *testing.T) {
 	service := NewDefaultService()
 	service.client.Timeout = time.Minute
 
-	stations, err := service.GetClimateStations()
+	stations, err := service.GetClimateStations(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, stations)
 }
 
+// stationsPageTime is a moment shortly after the stations_ok page was generated.
+var stationsPageTime = time.Date(2022, 10, 29, 13, 40, 0, 0, santiago)
+
 func TestParseStationHTMLOk(t *testing.T) {
 	page, err := test.LoadHTML("stations_ok")
 	if err != nil {
@@ -31,61 +37,135 @@ func TestParseStationHTMLOk(t *testing.T) {
 		t.Fatalf("unable to load test case json: %v", err)
 	}
 
-	// WARNING: Times are ignored since parsing at different locations introduces inconsistencies
-	// TODO: Add dedicated test for time
-	emptyTime := time.Time{}
+	got, err := parseClimateHTML(page, stationsPageTime)
+	assert.NoError(t, err)
 
-	for _, station := range expected {
-		if !station.Operational {
-			continue
+	for _, stations := range [][]*ClimateStation{expected, got} {
+		for _, station := range stations {
+			// Has a bugged character in the website. Invalid for both Windows-1254 and Unicode
+			if strings.Contains(station.Name, "El Huertón liceo agrícola") {
+				station.Name = "El Huertón liceo agrícola"
+			}
+
+			// Compare the instants regardless of the location they're expressed in
+			inUTC(station.LastReport)
+			if station.Operational {
+				inUTC(station.Today.Maximum.Time)
+				inUTC(station.Today.Minimum.Time)
+				inUTC(station.Yesterday.Maximum.Time)
+				inUTC(station.Yesterday.Minimum.Time)
+			}
 		}
+	}
 
-		// Has a bugged character in the website. Invalid for both Windows-1254 and Unicode
-		if strings.Contains(station.Name, "El Huertón liceo agrícola") {
-			station.Name = "El Huertón liceo agrícola"
-		}
+	assert.Equal(t, expected, got)
+}
+
+func inUTC(t *time.Time) {
+	if t != nil {
+		*t = t.UTC()
+	}
+}
 
-		last := emptyTime
-		station.LastReport = &last
-		station.Today.Minimum.Time = emptyTime
-		station.Today.Maximum.Time = emptyTime
-		station.Yesterday.Minimum.Time = emptyTime
-		station.Yesterday.Maximum.Time = emptyTime
+func TestParseStationsHTMLInvalidReader(t *testing.T) {
+	page, err := test.LoadHTML("stations_ok")
+	if err != nil {
+		t.Fatalf("unable to load test case html: %v", err)
 	}
 
-	got, err := parseClimateHTML(page)
+	page.Close()
+
+	got, err := parseClimateHTML(page, stationsPageTime)
+	assert.Error(t, err)
+	assert.Equal(t, ([]*ClimateStation)(nil), got)
+}
+
+func TestParseStationsHTMLLayoutChanged(t *testing.T) {
+	page, err := test.LoadHTML("indicators_ok")
+	if err != nil {
+		t.Fatalf("unable to load test case html: %v", err)
+	}
+
+	got, err := parseClimateHTML(page, stationsPageTime)
+	assert.True(t, upstream.IsLayoutChanged(err))
+	assert.Equal(t, ([]*ClimateStation)(nil), got)
+}
+
+func TestValueConversions(t *testing.T) {
+	value, err := tempToFloat64(" 0.0 ")
 	assert.NoError(t, err)
+	assert.Equal(t, float64Ptr(0), value)
 
-	for _, station := range got {
-		if !station.Operational {
-			continue
-		}
+	value, err = tempToFloat64(".")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
 
-		// Has a bugged character in the website. Invalid for both Windows-1254 and Unicode
-		if strings.Contains(station.Name, "El Huertón liceo agrícola") {
-			station.Name = "El Huertón liceo agrícola"
-		}
+	_, err = tempToFloat64("1O.2")
+	assert.Error(t, err)
+
+	value, _ = humidityToFloat64("59")
+	assert.Equal(t, float64Ptr(0.59), value)
+
+	value, _ = pressureToFloat64("1,010.1")
+	assert.Equal(t, float64Ptr(1010.1), value)
+
+	value, _ = precipitationsToFloat64("s/p")
+	assert.Equal(t, float64Ptr(0), value)
+
+	value, _ = precipitationsToFloat64(".")
+	assert.Nil(t, value)
+}
 
-		last := emptyTime
-		station.LastReport = &last
-		station.Today.Minimum.Time = emptyTime
-		station.Today.Maximum.Time = emptyTime
-		station.Yesterday.Minimum.Time = emptyTime
-		station.Yesterday.Maximum.Time = emptyTime
+func TestParseStationsHTMLAnomalies(t *testing.T) {
+	page, err := test.LoadHTML("stations_ok")
+	if err != nil {
+		t.Fatalf("unable to load test case html: %v", err)
 	}
 
-	assert.Equal(t, expected, got)
+	html, err := io.ReadAll(page)
+	if err != nil {
+		t.Fatalf("unable to read test case html: %v", err)
+	}
+
+	// Corrupt the current temperature and humidity of the first station
+	corrupted := strings.Replace(string(html), "> 18.4<", "> 18,4x<", 1)
+	corrupted = strings.Replace(corrupted, "> 59<", "> n/d<", 1)
+
+	got, err := parseClimateHTML(io.NopCloser(strings.NewReader(corrupted)), stationsPageTime)
+	assert.NoError(t, err)
+
+	assert.Nil(t, got[0].Temperature)
+	assert.Nil(t, got[0].Humidity)
+	assert.Equal(t, map[string]string{
+		"temperature": `unparsable value "18,4x"`,
+		"humidity":    `unparsable value "n/d"`,
+	}, got[0].Anomalies)
+	assert.Nil(t, got[0].Today.Maximum.Temperature, "not reported isn't an anomaly")
+	assert.Nil(t, got[1].Anomalies)
 }
 
-func TestParseStationsHTMLInvalidReader(t *testing.T) {
+func TestParseStationsHTMLMalformedHour(t *testing.T) {
 	page, err := test.LoadHTML("stations_ok")
 	if err != nil {
 		t.Fatalf("unable to load test case html: %v", err)
 	}
 
-	page.Close()
+	html, err := io.ReadAll(page)
+	if err != nil {
+		t.Fatalf("unable to read test case html: %v", err)
+	}
 
-	got, err := parseClimateHTML(page)
-	assert.Error(t, err)
-	assert.Equal(t, ([]*ClimateStation)(nil), got)
+	// The last report and today's maximum hours of the first station
+	corrupted := strings.Replace(string(html), "> 13:30<", "> 1330<", 1)
+	corrupted = strings.Replace(corrupted, "> 12:49<", "> 12<", 1)
+
+	got, err := parseClimateHTML(io.NopCloser(strings.NewReader(corrupted)), stationsPageTime)
+	assert.NoError(t, err)
+
+	assert.Nil(t, got[0].LastReport)
+	assert.Nil(t, got[0].Today.Maximum.Time)
+	assert.Equal(t, map[string]string{
+		"last_report":        `unparsable value "1330"`,
+		"today.maximum.time": `unparsable value "12"`,
+	}, got[0].Anomalies)
 }
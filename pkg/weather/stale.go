@@ -0,0 +1,28 @@
+package weather
+
+import (
+	"context"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+)
+
+// StaleService wraps a Service and falls back to the last successful result when the upstream fails. Stale results
+// are returned together with an upstream.StaleError.
+type StaleService struct {
+	service  Service
+	stations *upstream.LastKnownGood[[]*ClimateStation]
+}
+
+func NewStaleService(service Service, maxStaleness time.Duration) *StaleService {
+	return &StaleService{
+		service:  service,
+		stations: upstream.NewLastKnownGood[[]*ClimateStation](maxStaleness),
+	}
+}
+
+func (s *StaleService) GetClimateStations(ctx context.Context) ([]*ClimateStation, error) {
+	return s.stations.Fetch(func() ([]*ClimateStation, error) {
+		return s.service.GetClimateStations(ctx)
+	})
+}
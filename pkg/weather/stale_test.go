@@ -0,0 +1,39 @@
+package weather
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/upstream"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleServiceStations(t *testing.T) {
+	data := []*ClimateStation{
+		{
+			Code:        1,
+			Name:        "test1",
+			Operational: true,
+		},
+	}
+
+	mock := &MockService{stations: data}
+	service := NewStaleService(mock, time.Hour)
+
+	got, err := service.GetClimateStations(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	mock.stations = nil
+	mock.stationsErr = errors.New("server is on fire")
+
+	got, err = service.GetClimateStations(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, data, got)
+
+	_, stale := upstream.Staleness(err)
+	assert.True(t, stale)
+}
@@ -0,0 +1,109 @@
+package weather
+
+import (
+	"sort"
+)
+
+// unknownRegion groups the stations missing from the metadata table.
+const unknownRegion = "unknown"
+
+// StationReading is the value of a metric reported by a station.
+type StationReading struct {
+	Code  int     `json:"code"`
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// RegionSummary aggregates the current conditions of the stations of a region. Values are nil when no operational
+// station of the region reports them.
+type RegionSummary struct {
+	Region          string            `json:"region"`
+	Stations        int               `json:"stations"`
+	NonOperational  int               `json:"non_operational"`
+	MinTemperature  *StationReading   `json:"min_temperature"`
+	MaxTemperature  *StationReading   `json:"max_temperature"`
+	AverageHumidity *float64          `json:"average_humidity"`
+	Raining         []*StationReading `json:"raining"`
+
+	humiditySamples int
+	latitudeSum     float64
+	located         int
+}
+
+// summarizeRegions groups the stations by region, ordered from north to south by the mean latitude of their
+// stations.
+func summarizeRegions(stations []*ClimateStation) []*RegionSummary {
+	regions := make(map[string]*RegionSummary)
+	var ordered []*RegionSummary
+
+	for _, station := range stations {
+		name := unknownRegion
+		if station.Location != nil {
+			name = station.Location.Region
+		}
+
+		region, exists := regions[name]
+		if !exists {
+			region = &RegionSummary{Region: name, Raining: []*StationReading{}}
+			regions[name] = region
+			ordered = append(ordered, region)
+		}
+
+		region.add(station)
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.located == 0 || b.located == 0 {
+			return a.located != 0 && b.located == 0
+		}
+
+		return a.latitudeSum/float64(a.located) > b.latitudeSum/float64(b.located)
+	})
+
+	return ordered
+}
+
+func (r *RegionSummary) add(station *ClimateStation) {
+	r.Stations++
+
+	if station.Location != nil {
+		r.latitudeSum += station.Location.Latitude
+		r.located++
+	}
+
+	if !station.Operational {
+		r.NonOperational++
+		return
+	}
+
+	if station.Temperature != nil {
+		temperature := *station.Temperature
+
+		if r.MinTemperature == nil || temperature < r.MinTemperature.Value {
+			r.MinTemperature = &StationReading{Code: station.Code, Name: station.Name, Value: temperature}
+		}
+
+		if r.MaxTemperature == nil || temperature > r.MaxTemperature.Value {
+			r.MaxTemperature = &StationReading{Code: station.Code, Name: station.Name, Value: temperature}
+		}
+	}
+
+	if station.Humidity != nil {
+		r.humiditySamples++
+
+		if r.AverageHumidity == nil {
+			r.AverageHumidity = float64Ptr(0)
+		}
+
+		*r.AverageHumidity += (*station.Humidity - *r.AverageHumidity) / float64(r.humiditySamples)
+	}
+
+	if station.Today != nil && station.Today.Precipitations.Sum != nil && *station.Today.Precipitations.Sum > 0 {
+		r.Raining = append(r.Raining, &StationReading{
+			Code:  station.Code,
+			Name:  station.Name,
+			Value: *station.Today.Precipitations.Sum,
+		})
+	}
+}
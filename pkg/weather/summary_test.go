@@ -0,0 +1,72 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/ccuetoh/libreapi/internal/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeRegions(t *testing.T) {
+	stations := []*ClimateStation{
+		{Code: 1, Name: "north", Operational: true, Temperature: float64Ptr(20), Humidity: float64Ptr(0.4),
+			Location: &Location{Region: "A", Latitude: -20}},
+		{Code: 2, Name: "south1", Operational: true, Temperature: float64Ptr(5), Humidity: float64Ptr(0.8),
+			Location: &Location{Region: "B", Latitude: -50},
+			Today:    &ClimateReport{Precipitations: Precipitations{Sum: float64Ptr(3.2)}}},
+		{Code: 3, Name: "south2", Operational: true, Temperature: float64Ptr(-1), Humidity: float64Ptr(0.6),
+			Location: &Location{Region: "B", Latitude: -52},
+			Today:    &ClimateReport{Precipitations: Precipitations{Sum: float64Ptr(0)}}},
+		{Code: 4, Name: "south3", Operational: false, Location: &Location{Region: "B", Latitude: -51}},
+		{Code: 5, Name: "unlocated", Operational: true},
+	}
+
+	summaries := summarizeRegions(stations)
+	if !assert.Len(t, summaries, 3) {
+		return
+	}
+
+	assert.Equal(t, "A", summaries[0].Region)
+	assert.Equal(t, "B", summaries[1].Region)
+	assert.Equal(t, unknownRegion, summaries[2].Region)
+
+	south := summaries[1]
+	assert.Equal(t, 3, south.Stations)
+	assert.Equal(t, 1, south.NonOperational)
+	assert.Equal(t, &StationReading{Code: 3, Name: "south2", Value: -1}, south.MinTemperature)
+	assert.Equal(t, &StationReading{Code: 2, Name: "south1", Value: 5}, south.MaxTemperature)
+	assert.InDelta(t, 0.7, *south.AverageHumidity, 0.0001)
+	assert.Equal(t, []*StationReading{{Code: 2, Name: "south1", Value: 3.2}}, south.Raining)
+
+	unknown := summaries[2]
+	assert.Nil(t, unknown.MinTemperature)
+	assert.Nil(t, unknown.AverageHumidity)
+	assert.Empty(t, unknown.Raining)
+}
+
+func TestSummarizeRegionsFromPage(t *testing.T) {
+	page, err := test.LoadHTML("stations_ok")
+	if err != nil {
+		t.Fatalf("unable to load test case html: %v", err)
+	}
+
+	stations, err := parseClimateHTML(page, stationsPageTime)
+	if err != nil {
+		t.Fatalf("unable to parse test case html: %v", err)
+	}
+
+	locateStations(stations)
+	summaries := summarizeRegions(stations)
+
+	assert.Len(t, summaries, 16)
+	assert.Equal(t, "Arica y Parinacota", summaries[0].Region)
+	assert.Equal(t, "Magallanes", summaries[len(summaries)-1].Region)
+
+	total := 0
+	for _, summary := range summaries {
+		total += summary.Stations
+	}
+
+	assert.Equal(t, len(stations), total)
+}
@@ -0,0 +1,125 @@
+package weather
+
+import (
+	"math"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Units declares the unit of each converted value in a response. Fields keep their names regardless of the units, so
+// pressure_hpa holds the pressure in whatever unit Pressure states.
+type Units struct {
+	System           string `json:"system"`
+	Temperature      string `json:"temperature"`
+	Pressure         string `json:"pressure"`
+	Precipitation    string `json:"precipitation"`
+	Humidity         string `json:"humidity"`
+	AbsoluteHumidity string `json:"absolute_humidity"`
+}
+
+// unitSystem converts the metric values reported by meteochile. Results are rounded to a precision comparable to the
+// one of the source values.
+type unitSystem struct {
+	units            Units
+	temperature      func(celsius float64) float64
+	pressure         func(hpa float64) float64
+	precipitation    func(mm float64) float64
+	absoluteHumidity func(gm3 float64) float64
+}
+
+const defaultUnitSystem = "metric"
+
+var unitSystems = map[string]unitSystem{
+	"metric": {
+		units: Units{System: "metric", Temperature: "°C", Pressure: "hPa", Precipitation: "mm", Humidity: "ratio",
+			AbsoluteHumidity: "g/m³"},
+	},
+	"imperial": {
+		units: Units{System: "imperial", Temperature: "°F", Pressure: "inHg", Precipitation: "in", Humidity: "ratio",
+			AbsoluteHumidity: "gr/ft³"},
+		temperature:      func(celsius float64) float64 { return round(celsius*9/5+32, 2) },
+		pressure:         func(hpa float64) float64 { return round(hpa*0.0295299830714, 3) },
+		precipitation:    func(mm float64) float64 { return round(mm/25.4, 3) },
+		absoluteHumidity: func(gm3 float64) float64 { return round(gm3*0.436996, 2) },
+	},
+	"si": {
+		units: Units{System: "si", Temperature: "K", Pressure: "Pa", Precipitation: "m", Humidity: "ratio",
+			AbsoluteHumidity: "kg/m³"},
+		temperature:      func(celsius float64) float64 { return round(celsius+273.15, 2) },
+		pressure:         func(hpa float64) float64 { return round(hpa*100, 0) },
+		precipitation:    func(mm float64) float64 { return round(mm/1000, 4) },
+		absoluteHumidity: func(gm3 float64) float64 { return round(gm3/1000, 5) },
+	},
+}
+
+// parseUnitSystem reads the units parameter of the request, defaulting to metric.
+func parseUnitSystem(c *gin.Context) (unitSystem, bool) {
+	system, exists := unitSystems[c.DefaultQuery("units", defaultUnitSystem)]
+	return system, exists
+}
+
+// convert returns copies of the stations with their values in the unit system. The stations are never modified
+// since they might be shared with other requests.
+func (u unitSystem) convert(stations []*ClimateStation) []*ClimateStation {
+	if u.temperature == nil {
+		return stations
+	}
+
+	converted := make([]*ClimateStation, len(stations))
+	for i, station := range stations {
+		converted[i] = u.convertStation(station)
+	}
+
+	return converted
+}
+
+func (u unitSystem) convertStation(station *ClimateStation) *ClimateStation {
+	if u.temperature == nil {
+		return station
+	}
+
+	result := *station
+	result.Temperature = convertValue(station.Temperature, u.temperature)
+	result.PressureHPA = convertValue(station.PressureHPA, u.pressure)
+	result.Today = u.convertReport(station.Today)
+	result.Yesterday = u.convertReport(station.Yesterday)
+
+	if station.Derived != nil {
+		result.Derived = &DerivedMetrics{
+			DewPoint:            convertValue(station.Derived.DewPoint, u.temperature),
+			HeatIndex:           convertValue(station.Derived.HeatIndex, u.temperature),
+			ApparentTemperature: convertValue(station.Derived.ApparentTemperature, u.temperature),
+			AbsoluteHumidity:    convertValue(station.Derived.AbsoluteHumidity, u.absoluteHumidity),
+		}
+	}
+
+	return &result
+}
+
+func (u unitSystem) convertReport(report *ClimateReport) *ClimateReport {
+	if report == nil {
+		return nil
+	}
+
+	result := *report
+	result.Maximum.Temperature = convertValue(report.Maximum.Temperature, u.temperature)
+	result.Minimum.Temperature = convertValue(report.Minimum.Temperature, u.temperature)
+	result.Precipitations.Sum = convertValue(report.Precipitations.Sum, u.precipitation)
+	result.Precipitations.EMA = convertValue(report.Precipitations.EMA, u.precipitation)
+
+	return &result
+}
+
+func convertValue(value *float64, convert func(float64) float64) *float64 {
+	if value == nil {
+		return nil
+	}
+
+	result := convert(*value)
+	return &result
+}
+
+func round(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,53 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func unitsTestStation() *ClimateStation {
+	return &ClimateStation{
+		Code:        330020,
+		Operational: true,
+		Temperature: float64Ptr(18.4),
+		Humidity:    float64Ptr(0.59),
+		PressureHPA: float64Ptr(1013.2),
+		Today: &ClimateReport{
+			Maximum:        Measurement{Temperature: float64Ptr(-40)},
+			Minimum:        Measurement{Temperature: nil},
+			Precipitations: Precipitations{Sum: float64Ptr(25.4), EMA: float64Ptr(0)},
+		},
+	}
+}
+
+func TestUnitConversionImperial(t *testing.T) {
+	station := unitsTestStation()
+	got := unitSystems["imperial"].convert([]*ClimateStation{station})[0]
+
+	assert.Equal(t, float64Ptr(65.12), got.Temperature)
+	assert.Equal(t, float64Ptr(0.59), got.Humidity)
+	assert.Equal(t, float64Ptr(29.92), got.PressureHPA)
+	assert.Equal(t, float64Ptr(-40), got.Today.Maximum.Temperature)
+	assert.Nil(t, got.Today.Minimum.Temperature)
+	assert.Equal(t, float64Ptr(1), got.Today.Precipitations.Sum)
+	assert.Equal(t, float64Ptr(0), got.Today.Precipitations.EMA)
+	assert.Nil(t, got.Yesterday)
+
+	// The original station might be shared with other requests
+	assert.Equal(t, unitsTestStation(), station)
+}
+
+func TestUnitConversionSI(t *testing.T) {
+	got := unitSystems["si"].convertStation(unitsTestStation())
+
+	assert.Equal(t, float64Ptr(291.55), got.Temperature)
+	assert.Equal(t, float64Ptr(101320), got.PressureHPA)
+	assert.Equal(t, float64Ptr(233.15), got.Today.Maximum.Temperature)
+	assert.Equal(t, float64Ptr(0.0254), got.Today.Precipitations.Sum)
+}
+
+func TestUnitConversionMetric(t *testing.T) {
+	station := unitsTestStation()
+	assert.Same(t, station, unitSystems["metric"].convertStation(station))
+}
@@ -0,0 +1,147 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SignatureHeader = "X-LibreAPI-Signature"
+	TimestampHeader = "X-LibreAPI-Timestamp"
+)
+
+// notificationQueueSize is how many alerts may wait for delivery before new ones are dropped.
+const notificationQueueSize = 100
+
+type delivery struct {
+	webhook string
+	alert   Alert
+}
+
+// Notifier delivers alerts to webhooks in the background. Failed deliveries are retried with an exponential backoff.
+// Every request is signed with SignPayload so receivers can verify it came from this server.
+type Notifier struct {
+	env         *env.Env
+	client      *http.Client
+	secret      []byte
+	maxAttempts int
+	backoff     time.Duration
+	queue       chan delivery
+	now         func() time.Time
+}
+
+func NewNotifier(env *env.Env, secret string, maxAttempts int, backoff time.Duration) *Notifier {
+	return &Notifier{
+		env:         env,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		secret:      []byte(secret),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		queue:       make(chan delivery, notificationQueueSize),
+		now:         time.Now,
+	}
+}
+
+// SignPayload returns the signature of a webhook request, the hex encoded HMAC-SHA256 of the timestamp header, a
+// dot and the body. Including the timestamp lets receivers reject replayed requests.
+func SignPayload(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify queues an alert for delivery. It never blocks, alerts are dropped if the queue is full.
+func (n *Notifier) Notify(webhook string, alert Alert) {
+	select {
+	case n.queue <- delivery{webhook: webhook, alert: alert}:
+	default:
+		n.env.Logger.Errorf("alert queue full, dropping %s alert of rule %s", alert.Event, alert.Rule)
+	}
+}
+
+// Run delivers the queued alerts until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-n.queue:
+			err := n.deliver(ctx, d)
+			if err != nil {
+				n.env.Logger.Errorf("unable to deliver %s alert of rule %s: %v", d.alert.Event, d.alert.Rule, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, d delivery) error {
+	body, err := json.Marshal(d.alert)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.send(ctx, d.webhook, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= n.maxAttempts {
+			return errors.Wrapf(err, "giving up after %d attempts", attempt)
+		}
+
+		n.env.Logger.Warnf("alert delivery attempt %d to %s failed, retrying in %v: %v", attempt, d.webhook, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// send makes a single delivery attempt, reporting whether a failure is worth retrying.
+func (n *Notifier) send(ctx context.Context, webhook string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(n.now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, SignPayload(n.secret, timestamp, body))
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("status code error: %s", res.Status)
+	return res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests, err
+}
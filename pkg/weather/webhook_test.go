@@ -0,0 +1,92 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccuetoh/libreapi/pkg/env"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifierDeliversSigned(t *testing.T) {
+	var attempts int32
+	received := make(chan Alert, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		timestamp := r.Header.Get(TimestampHeader)
+		assert.Equal(t, "1667064000", timestamp)
+		assert.Equal(t, SignPayload([]byte("secret"), timestamp, body), r.Header.Get(SignatureHeader))
+
+		var alert Alert
+		assert.NoError(t, json.Unmarshal(body, &alert))
+		received <- alert
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(env.NewTestEnv(), "secret", 5, time.Millisecond)
+	notifier.now = func() time.Time { return time.Unix(1667064000, 0) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go notifier.Run(ctx)
+	notifier.Notify(server.URL, Alert{ID: "1", Event: AlertTriggered, Rule: "freezing", Value: -1})
+
+	select {
+	case alert := <-received:
+		assert.Equal(t, "freezing", alert.Rule)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert not delivered")
+	}
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(env.NewTestEnv(), "secret", 3, time.Millisecond)
+
+	err := notifier.deliver(context.Background(), delivery{webhook: server.URL, alert: Alert{Rule: "freezing"}})
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestNotifierDoesNotRetryClientErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(env.NewTestEnv(), "secret", 3, time.Millisecond)
+
+	err := notifier.deliver(context.Background(), delivery{webhook: server.URL, alert: Alert{Rule: "freezing"}})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestSignPayload(t *testing.T) {
+	// Reference computed with: printf '1667064000.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=92334c404cfd3ca4aa67ce13ef9ce5062570f0cd3f88cca6bd3168ab1271b809",
+		SignPayload([]byte("secret"), "1667064000", []byte("{}")))
+}